@@ -0,0 +1,147 @@
+package evidence
+
+import (
+	"math"
+)
+
+// Evidence is described by Beta(P+1, N+1) distribution of the probability of positive outcome:
+// uniform prior distribution updated by P positive and N negative observations.
+
+// Alpha returns the first parameter of Beta distribution of the evidence
+func (e Type) Alpha() float64 { return e.P + 1 }
+
+// Beta returns the second parameter of Beta distribution of the evidence
+func (e Type) Beta() float64 { return e.N + 1 }
+
+// Mean returns mean of Beta distribution of the evidence
+func (e Type) Mean() float64 {
+	return e.Alpha() / (e.Alpha() + e.Beta())
+}
+
+// Variance returns variance of Beta distribution of the evidence
+func (e Type) Variance() float64 {
+	a, b := e.Alpha(), e.Beta()
+	s := a + b
+	return a * b / (s * s * (s + 1))
+}
+
+// StdDev returns standard deviation of Beta distribution of the evidence
+func (e Type) StdDev() float64 {
+	return math.Sqrt(e.Variance())
+}
+
+// Mode returns mode of Beta distribution of the evidence: P/(P+N).
+// Distribution of the empty evidence is uniform, 1/2 is returned as its mode.
+func (e Type) Mode() float64 {
+	if e.Total() == 0 {
+		return 0.5
+	}
+	return e.P / e.Total()
+}
+
+// CredibleInterval returns equal-tailed interval containing probability of positive outcome
+// with the given confidence in (0, 1), e.g. 0.95
+func (e Type) CredibleInterval(confidence float64) (lower, upper float64) {
+	tail := (1 - confidence) / 2
+	return e.Quantile(tail), e.Quantile(1 - tail)
+}
+
+// Quantile returns x such that probability of positive outcome is at most x with probability q in [0, 1]
+func (e Type) Quantile(q float64) float64 {
+	switch {
+	case q <= 0:
+		return 0
+	case q >= 1:
+		return 1
+	}
+
+	// CDF is monotone, so bisection always converges
+	a, b := e.Alpha(), e.Beta()
+	lo, hi := 0.0, 1.0
+	for i := 0; i < 100 && hi-lo > 1e-15; i++ {
+		x := (lo + hi) / 2
+		if regularizedIncompleteBeta(a, b, x) < q {
+			lo = x
+		} else {
+			hi = x
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// CDF returns probability that probability of positive outcome is at most x
+func (e Type) CDF(x float64) float64 {
+	switch {
+	case x <= 0:
+		return 0
+	case x >= 1:
+		return 1
+	}
+	return regularizedIncompleteBeta(e.Alpha(), e.Beta(), x)
+}
+
+// regularizedIncompleteBeta returns I_x(a, b) using continued fraction expansion
+func regularizedIncompleteBeta(a, b, x float64) float64 {
+	lbeta, _ := math.Lgamma(a + b)
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	front := math.Exp(lbeta - la - lb + a*math.Log(x) + b*math.Log1p(-x))
+
+	// continued fraction converges quickly for x < (a+1)/(a+b+2), symmetry relation is used otherwise
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(a, b, x) / a
+	}
+	return 1 - front*betaContinuedFraction(b, a, 1-x)/b
+}
+
+// betaContinuedFraction evaluates continued fraction of the incomplete beta function by modified Lentz's method
+func betaContinuedFraction(a, b, x float64) float64 {
+	const (
+		maxIterations = 300
+		epsilon       = 1e-16
+		tiny          = 1e-300
+	)
+
+	qab, qap, qam := a+b, a+1, a-1
+	c, d := 1.0, 1-qab*x/qap
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= maxIterations; m++ {
+		m := float64(m)
+		m2 := 2 * m
+
+		// even step
+		aa := m * (b - m) * x / ((qam + m2) * (a + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		// odd step
+		aa = -(a + m) * (qab + m) * x / ((a + m2) * (qap + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
@@ -0,0 +1,54 @@
+package evidence
+
+import (
+	"math"
+	"time"
+)
+
+// DecayFun returns weight in [0, 1] of the evidence of the given age, evidence is scaled by the weight
+type DecayFun func(age time.Duration) float64
+
+// NoDecay keeps evidence of any age
+func NoDecay(time.Duration) float64 { return 1 }
+
+// ExponentialDecay halves weight of the evidence every `halfLife`
+func ExponentialDecay(halfLife time.Duration) DecayFun {
+	return func(age time.Duration) float64 {
+		if age <= 0 {
+			return 1
+		}
+		return math.Exp2(-float64(age) / float64(halfLife))
+	}
+}
+
+// SlidingWindowDecay keeps evidence not older than `window` and forgets older evidence
+func SlidingWindowDecay(window time.Duration) DecayFun {
+	return StepCutoffDecay(DecayStep{MaxAge: window, Weight: 1})
+}
+
+// DecayStep is a weight of the evidence not older than MaxAge
+type DecayStep struct {
+	MaxAge time.Duration
+	Weight float64
+}
+
+// StepCutoffDecay uses weight of the first step evidence is not older than, steps must be sorted by age.
+// Evidence older than the last step is forgotten.
+func StepCutoffDecay(steps ...DecayStep) DecayFun {
+	return func(age time.Duration) float64 {
+		for _, step := range steps {
+			if age <= step.MaxAge {
+				return step.Weight
+			}
+		}
+		return 0
+	}
+}
+
+// Decayed returns evidence scaled by the weight of its age, evidence from the future (negative age) is not decayed
+func Decayed(e Type, decay DecayFun, age time.Duration) Type {
+	if age < 0 {
+		age = 0
+	}
+	return e.Scale(decay(age))
+}
@@ -16,3 +16,18 @@ func (e Type) String() string {
 func New(p, n float64) Type {
 	return Type{P: p, N: n}
 }
+
+// Add returns sum of the evidences (cumulative fusion)
+func (e Type) Add(other Type) Type {
+	return Type{P: e.P + other.P, N: e.N + other.N}
+}
+
+// Scale returns evidence scaled by the weight
+func (e Type) Scale(w float64) Type {
+	return Type{P: w * e.P, N: w * e.N}
+}
+
+// Total returns total amount of evidence
+func (e Type) Total() float64 {
+	return e.P + e.N
+}
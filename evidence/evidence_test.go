@@ -0,0 +1,90 @@
+package evidence_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dimchansky/ebsl-go/evidence"
+	"github.com/go-test/deep"
+)
+
+func TestType_Arithmetic(t *testing.T) {
+	x := evidence.New(3, 1)
+	y := evidence.New(1, 4)
+
+	if diff := deep.Equal(x.Add(y), evidence.New(4, 5)); diff != nil {
+		t.Errorf("Add: %v", diff)
+	}
+	if diff := deep.Equal(x.Scale(0.5), evidence.New(1.5, 0.5)); diff != nil {
+		t.Errorf("Scale: %v", diff)
+	}
+	if diff := deep.Equal(evidence.Decayed(x, evidence.ExponentialDecay(time.Hour), 2*time.Hour), evidence.New(0.75, 0.25)); diff != nil {
+		t.Errorf("Decayed: %v", diff)
+	}
+	if diff := deep.Equal(evidence.Decayed(x, evidence.SlidingWindowDecay(time.Hour), -2*time.Hour), x); diff != nil {
+		t.Errorf("Decayed future evidence: %v", diff)
+	}
+	if got := x.Total(); got != 4 {
+		t.Errorf("Total: got %v, want 4", got)
+	}
+}
+
+func TestType_Beta(t *testing.T) {
+	tests := []struct {
+		name                     string
+		e                        evidence.Type
+		mean, variance, mode     float64
+		confidence, lower, upper float64
+	}{
+		{"empty", evidence.New(0, 0), 0.5, 1.0 / 12, 0.5, 0.9, 0.05, 0.95},
+		{"positive", evidence.New(1, 0), 2.0 / 3, 1.0 / 18, 1, 0.5, 0.5, math.Sqrt(0.75)},
+		{"negative", evidence.New(0, 1), 1.0 / 3, 1.0 / 18, 0, 0.5, 1 - math.Sqrt(0.75), 0.5},
+		{"mixed", evidence.New(8, 2), 0.75, 27.0 / (144 * 13), 0.8, 0.95, 0.4822441, 0.9397823},
+	}
+	const eps = 1e-7
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := func(name string, got, want float64) {
+				if math.Abs(got-want) > eps {
+					t.Errorf("%v: got %v, want %v", name, got, want)
+				}
+			}
+
+			check("Mean", tt.e.Mean(), tt.mean)
+			check("Variance", tt.e.Variance(), tt.variance)
+			check("StdDev", tt.e.StdDev(), math.Sqrt(tt.variance))
+			check("Mode", tt.e.Mode(), tt.mode)
+
+			lower, upper := tt.e.CredibleInterval(tt.confidence)
+			check("lower bound", lower, tt.lower)
+			check("upper bound", upper, tt.upper)
+			check("CDF of lower bound", tt.e.CDF(lower), (1-tt.confidence)/2)
+			check("CDF of upper bound", tt.e.CDF(upper), (1+tt.confidence)/2)
+		})
+	}
+}
+
+func TestMultinomial_Arithmetic(t *testing.T) {
+	x := evidence.NewMultinomial(3, 1, 2)
+	y := evidence.NewMultinomial(1, 4)
+
+	if diff := deep.Equal(x.Add(y), evidence.NewMultinomial(4, 5, 2)); diff != nil {
+		t.Errorf("Add: %v", diff)
+	}
+	if diff := deep.Equal(y.Add(x), evidence.NewMultinomial(4, 5, 2)); diff != nil {
+		t.Errorf("Add shorter evidence: %v", diff)
+	}
+	if diff := deep.Equal(x.Scale(0.5), evidence.NewMultinomial(1.5, 0.5, 1)); diff != nil {
+		t.Errorf("Scale: %v", diff)
+	}
+	if got := x.Total(); got != 6 {
+		t.Errorf("Total: got %v, want 6", got)
+	}
+	if diff := deep.Equal(x, evidence.NewMultinomial(3, 1, 2)); diff != nil {
+		t.Errorf("evidence is modified: %v", diff)
+	}
+	if diff := deep.Equal(evidence.New(3, 1).ToMultinomial(), evidence.NewMultinomial(3, 1)); diff != nil {
+		t.Errorf("ToMultinomial: %v", diff)
+	}
+}
@@ -0,0 +1,57 @@
+package evidence
+
+import "fmt"
+
+// Multinomial is evidence about a proposition with several mutually exclusive outcomes (e.g. good / late / fraudulent):
+// amount of evidence per outcome. Missing outcomes of shorter evidence have no evidence.
+type Multinomial []float64
+
+// String implements fmt.Stringer
+func (e Multinomial) String() string {
+	return fmt.Sprintf("%v", []float64(e))
+}
+
+// NewMultinomial creates new instance of multinomial evidence
+func NewMultinomial(evidence ...float64) Multinomial {
+	return Multinomial(evidence)
+}
+
+// ToMultinomial returns binomial evidence as multinomial evidence of positive and negative outcomes
+func (e Type) ToMultinomial() Multinomial {
+	return Multinomial{e.P, e.N}
+}
+
+// Outcomes returns number of outcomes of the evidence
+func (e Multinomial) Outcomes() int {
+	return len(e)
+}
+
+// Add returns sum of the evidences (cumulative fusion)
+func (e Multinomial) Add(other Multinomial) Multinomial {
+	if len(e) < len(other) {
+		e, other = other, e
+	}
+	res := make(Multinomial, len(e))
+	copy(res, e)
+	for i, v := range other {
+		res[i] += v
+	}
+	return res
+}
+
+// Scale returns evidence scaled by the weight
+func (e Multinomial) Scale(w float64) Multinomial {
+	res := make(Multinomial, len(e))
+	for i, v := range e {
+		res[i] = w * v
+	}
+	return res
+}
+
+// Total returns total amount of evidence
+func (e Multinomial) Total() (total float64) {
+	for _, v := range e {
+		total += v
+	}
+	return
+}
@@ -2,60 +2,394 @@ package main
 
 import (
 	"bufio"
-	"bytes"
+	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/dimchansky/ebsl-go/evidence"
+	"github.com/dimchansky/ebsl-go/opinion"
 	"github.com/dimchansky/ebsl-go/trust"
 	"github.com/dimchansky/ebsl-go/trust/equations"
 	"github.com/dimchansky/ebsl-go/trust/equations/solver"
+	"github.com/dimchansky/ebsl-go/trust/evidenceio"
 )
 
 func main() {
-	if len(os.Args) != 4 {
-		fmt.Printf("Usage: %s <threshold> <evidence_file_name> <final_referral_trust_output_file>\n", os.Args[0])
+	discountName := flag.String("discount", "belief", "discount function: "+
+		"belief, sqrt, projected[:<base rate>], threshold[:<belief threshold>], evidence[:<positive evidence giving 0.5>]")
+	workers := flag.Uint("workers", 1, "number of workers solving equations of different source nodes in parallel")
+	method := flag.String("method", "fixed-point", "method of solving final referral trust equations: "+
+		"fixed-point, newton or topological (equations outside of cycles are solved directly)")
+	initName := flag.String("init", "belief", "initial value of final referral trust: "+
+		"belief, uncertainty, direct (direct referral trust where it exists), topological (single pass estimate) "+
+		"or solution:<file> (discounts of the final referral trust output file as beliefs of the opinions, belief discount only)")
+	updateMode := flag.String("update", "gauss-seidel", "update mode of the solver: gauss-seidel or jacobi")
+	andersonDepth := flag.Uint("anderson-depth", 0, "number of recent epochs used by Anderson acceleration of the solver (disabled by default)")
+	perSource := flag.Bool("per-source-convergence", false, "stop evaluating equations of source nodes that already converged")
+	maxEpochs := flag.Uint("max-epochs", 100, "maximum number of epochs to solve equations")
+	tolerance := flag.Float64("tolerance", 0, "solving stops when aggregated distance between epochs is within tolerance")
+	verifyTolerance := flag.Float64("verify-tolerance", 1e-6, "verification fails when residual of some link exceeds tolerance")
+	verifyThreshold := flag.Uint64("threshold", 0, "threshold of the evidence used by verify command (required by verify, "+
+		"solving takes threshold as the first argument)")
+	requireConvergence := flag.Bool("require-convergence", false, "exit with non-zero code if equations did not converge")
+	timeout := flag.Duration("timeout", 0, "stop solving when timeout is exceeded (no timeout by default)")
+	deterministic := flag.Bool("deterministic", false, "generate and solve equations in a stable sorted order to get reproducible results")
+	inputFormat := flag.String("input-format", "auto", "format of evidence files: "+
+		"auto (detected by file extension or by the first record), whitespace, csv, tsv or jsonl")
+	aggregateName := flag.String("aggregate", "last", "aggregation of evidence records of the same link: "+
+		"last (only the last record is kept), sum (every record is a separate event), average, max or reject")
+	decayName := flag.String("decay", "none", "decay of timestamped evidence by its age at the reference time: none, "+
+		"exponential:<half-life>, window:<max age> or step:<max age>=<weight>[,<max age>=<weight>...] "+
+		"(durations are like 36h or 30d, older evidence than the last step is forgotten)")
+	referenceTime := flag.String("reference-time", "", "reference time of evidence decay in RFC 3339 format (current time by default)")
+	maxErrors := flag.Uint("max-errors", 10, "reading of evidence fails after the given number of invalid records (0 for no limit, with --skip-invalid applies only if set explicitly)")
+	skipInvalid := flag.Bool("skip-invalid", false, "skip invalid evidence records after reporting them instead of failing (no limit of invalid records unless --max-errors is set)")
+	labels := flag.Bool("labels", false, "nodes of the files are arbitrary string labels without whitespace "+
+		"(emails, DIDs, public keys, etc.) instead of non-negative integers")
+	baseRate := flag.Float64("base-rate", opinion.DefaultBaseRate, "base rate (prior probability) in [0, 1] "+
+		"used by --projected-probability, it is applied to solved opinions and is not propagated through trust equations")
+	projected := flag.Bool("projected-probability", false, "write projected probability b + a·u of final functional trust "+
+		"using the base rate instead of belief, disbelief and uncertainty (requires functional evidence and output files)")
+	var sources sourcesFlag
+	flag.Var(&sources, "source", "compute trust of the given source node only (can be repeated, all source nodes by default)")
+	flag.Usage = func() {
+		fmt.Printf("Usage: %s [flags] <threshold> <evidence_file_name> <final_referral_trust_output_file> "+
+			"[<functional_evidence_file_name> <final_functional_trust_output_file>]\n", os.Args[0])
+		fmt.Printf("       %s [flags] --threshold <threshold> verify <evidence_file_name> <final_referral_trust_file>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+
+	format, err := evidenceio.ParseFormat(*inputFormat)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	aggregate, err := parseAggregation(*aggregateName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	decay, err := parseDecay(*decayName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	reference := time.Now()
+	if *referenceTime != "" {
+		if reference, err = time.Parse(time.RFC3339, *referenceTime); err != nil {
+			fmt.Printf("invalid reference time (%v): %v\n", *referenceTime, err)
+			os.Exit(1)
+		}
+	}
+	if *baseRate < 0 || *baseRate > 1 {
+		fmt.Printf("invalid base rate (%v): must be in [0, 1]\n", *baseRate)
+		os.Exit(1)
+	}
+	if isFlagSet("base-rate") && !*projected {
+		fmt.Println("--base-rate is used only with --projected-probability")
+		os.Exit(1)
+	}
+	if *projected && len(args) != 5 {
+		fmt.Println("--projected-probability requires functional evidence and final functional trust output files")
 		os.Exit(1)
 	}
+	if *skipInvalid && !isFlagSet("max-errors") {
+		*maxErrors = 0
+	}
+	nodes := newNodeFormat(*labels)
+	reader := evidenceReader{
+		format:      format,
+		nodes:       nodes,
+		aggregate:   aggregate,
+		decay:       decay,
+		reference:   reference,
+		maxErrors:   *maxErrors,
+		skipInvalid: *skipInvalid,
+	}
 
-	threshold, inputFileName, outputFileName := parseCmdLineParams()
+	if len(args) == 3 && args[0] == "verify" {
+		threshold, inputFileName, solutionFileName := *verifyThreshold, args[1], args[2]
+		if threshold == 0 {
+			fmt.Println("verify requires positive --threshold of the evidence")
+			os.Exit(1)
+		}
+		discount, _, err := parseDiscount(*discountName, threshold)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		verify(threshold, inputFileName, solutionFileName, reader, discount, *verifyTolerance, sources)
+		return
+	}
+	if len(args) != 3 && len(args) != 5 {
+		flag.Usage()
+		os.Exit(1)
+	}
 
-	parser := evidenceFileParser{inputFileName}
+	threshold, inputFileName, outputFileName := parseCmdLineParams(args)
 
-	dro := make(trust.DirectReferralOpinion).FromIterableEvidences(parser, threshold)
+	discount, discountGradient, err := parseDiscount(*discountName, threshold)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	initialization, err := parseInitialization(*initName, *discountName, nodes)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	solverUpdateMode, err := parseUpdateMode(*updateMode)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	dre, err := reader.read(inputFileName)
+	if err != nil {
+		fmt.Printf("failed to read evidence file: %v\n", err)
+		os.Exit(1)
+	}
+	dro := trust.DirectReferralEvidence(dre).ToDirectReferralOpinion(threshold)
+
+	var eqsOpts []equations.EquationsOption
+	if *deterministic {
+		eqsOpts = append(eqsOpts, equations.UseSortedOrder())
+	}
+	if len(sources) > 0 {
+		sourceIDs, err := nodes.lookup(sources)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		eqsOpts = append(eqsOpts, equations.UseSources(sourceIDs...))
+	}
+
+	initial, err := initialization(dro, eqsOpts, discount)
+	if err != nil {
+		fmt.Printf("failed to initialize final referral trust: %v\n", err)
+		os.Exit(1)
+	}
+	frtContext, finalReferralTrust := newFinalReferralTrustEquationContext(dro, *workers,
+		equations.UseDiscount(discount), equations.UseDiscountGradient(discountGradient), equations.UseInitialization(initial))
 
 	log.Println("Creating Final Referral Trust equations...")
-	eqs := equations.CreateFinalReferralTrustEquations(dro)
+	solve, err := newSolveFun(*method, dro, frtContext, eqsOpts)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 	log.Println("Final Referral Trust equations are created.")
 
-	context := equations.NewDefaultFinalReferralTrustEquationContext(dro)
+	solverOpts := []solver.Options{
+		solver.UseMaxEpochs(*maxEpochs),
+		solver.UseTolerance(*tolerance),
+		solver.UseWorkers(*workers),
+		solver.UseUpdateMode(solverUpdateMode),
+	}
+	if *andersonDepth > 0 {
+		solverOpts = append(solverOpts, solver.UseAndersonAcceleration(*andersonDepth))
+	}
+	if *perSource {
+		solverOpts = append(solverOpts,
+			solver.UsePerSourceConvergence(),
+			solver.UseOnActiveEquationsCallback(func(epoch uint, activeEquations int) error {
+				log.Printf("Epoch %v active equations: %v\n", epoch, activeEquations)
+				return nil
+			}),
+		)
+	}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
 
 	log.Println("Solving Final Referral Trust equations...")
-	if err := solver.SolveFinalReferralTrustEquations(
-		context,
-		eqs,
-		solver.UseOnEpochEndCallback(func(epoch uint, aggregatedDistance float64) error {
+	res, err := solve(
+		ctx,
+		append(solverOpts, solver.UseOnEpochEndCallback(func(epoch uint, aggregatedDistance float64) error {
 			log.Printf("Epoch %v error: %v\n", epoch, aggregatedDistance)
 			return nil
-		}),
-	); err != nil {
+		}))...,
+	)
+	if err != nil {
 		log.Fatal(err)
 	}
-	log.Println("Final Referral Trust equations are solved.")
+	log.Printf("Final Referral Trust equations are solved: %v\n", res)
+	for _, ld := range res.SlowestLinks {
+		if ld.Distance == 0 {
+			break
+		}
+		log.Printf("Slowest converging link %v distance: %v\n", nodes.link(ld.Link), ld.Distance)
+	}
 
 	log.Println("Writing final referral trust discount values to file...")
-	if err := writeFinalReferralTrustDiscount(outputFileName, context); err != nil {
+	if err := writeFinalReferralTrustDiscount(outputFileName, finalReferralTrust(), frtContext, nodes); err != nil {
 		fmt.Printf("failed to write final referral trust discounts to file: %v\n", err)
 		os.Exit(2)
 	}
+
+	if len(args) == 5 {
+		functionalInputFileName, functionalOutputFileName := args[3], args[4]
+
+		dfe, err := reader.read(functionalInputFileName)
+		if err != nil {
+			fmt.Printf("failed to read functional evidence file: %v\n", err)
+			os.Exit(1)
+		}
+		dfo := trust.DirectFunctionalEvidence(dfe).ToDirectFunctionalOpinion(threshold)
+
+		log.Println("Creating Final Functional Trust equations...")
+		feqs := equations.CreateFinalFunctionalTrustEquations(dro, dfo, eqsOpts...)
+		log.Println("Final Functional Trust equations are created.")
+
+		functionalContext := equations.NewDefaultFinalFunctionalTrustEquationContext(frtContext, dfo)
+
+		log.Println("Solving Final Functional Trust equations...")
+		if err := solver.SolveFinalFunctionalTrustEquations(functionalContext, feqs); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Final Functional Trust equations are solved.")
+
+		log.Println("Writing final functional trust values to file...")
+		write := func() error {
+			return writeFinalFunctionalTrust(functionalOutputFileName, functionalContext.FinalFunctionalTrust, nodes)
+		}
+		if *projected {
+			write = func() error {
+				return writeFinalFunctionalTrustProbability(functionalOutputFileName, functionalContext.FinalFunctionalTrust, *baseRate, nodes)
+			}
+		}
+		if err := write(); err != nil {
+			fmt.Printf("failed to write final functional trust to file: %v\n", err)
+			os.Exit(2)
+		}
+	}
 	log.Println("Done.")
+
+	if *requireConvergence && !res.Converged {
+		fmt.Println("final referral trust equations did not converge")
+		os.Exit(3)
+	}
 }
 
-func parseCmdLineParams() (threshold uint64, inputFileName string, outputFileName string) {
-	thresholdStr := os.Args[1]
+// verify checks that final referral trust discounts of the solution file satisfy equations of the evidence file
+func verify(
+	threshold uint64,
+	inputFileName, solutionFileName string,
+	reader evidenceReader,
+	discount equations.DiscountFun,
+	tolerance float64,
+	sources []string,
+) {
+	dre, err := reader.read(inputFileName)
+	if err != nil {
+		fmt.Printf("failed to read evidence file: %v\n", err)
+		os.Exit(1)
+	}
+	dro := trust.DirectReferralEvidence(dre).ToDirectReferralOpinion(threshold)
+	nodes := reader.nodes
+
+	discounts, err := readFinalReferralTrustDiscount(solutionFileName, nodes)
+	if err != nil {
+		fmt.Printf("failed to read final referral trust discounts from file: %v\n", err)
+		os.Exit(2)
+	}
+
+	eqsOpts := []equations.EquationsOption{equations.UseSortedOrder()}
+	if len(sources) > 0 {
+		sourceIDs, err := nodes.lookup(sources)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		eqsOpts = append(eqsOpts, equations.UseSources(sourceIDs...))
+	}
+
+	eqs := equations.CreateFinalReferralTrustEquations(dro, eqsOpts...)
+	foreachEquation := eqs.GetFinalReferralTrustEquationIterator()
+	if err := foreachEquation(func(eq *equations.FinalReferralTrustEquation) error {
+		if _, ok := discounts[eq.R]; !ok {
+			return fmt.Errorf("%v: no discount of link %v", solutionFileName, nodes.link(eq.R))
+		}
+		return nil
+	}); err != nil {
+		fmt.Printf("failed to read final referral trust discounts from file: %v\n", err)
+		os.Exit(2)
+	}
+
+	log.Println("Verifying Final Referral Trust...")
+	res, err := equations.Verify(
+		discountSolutionContext{dro: dro, discounts: discounts},
+		eqs,
+		equations.UseResidualFunction(func(link trust.Link, value, evaluated *opinion.Type) float64 {
+			return math.Abs(value.B - discount(*evaluated))
+		}),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Final Referral Trust is verified: %v equations, max residual: %v\n", res.Equations, res.MaxResidual)
+	for _, lr := range res.WorstLinks {
+		if lr.Residual == 0 {
+			break
+		}
+		log.Printf("Link %v discount: %v evaluated: %v residual: %v\n", nodes.link(lr.Link), lr.Value.B, discount(lr.Evaluated), lr.Residual)
+	}
+
+	if res.MaxResidual > tolerance {
+		fmt.Println("final referral trust does not satisfy equations")
+		os.Exit(3)
+	}
+}
+
+// discountSolutionContext evaluates expressions using discounts of the final referral trust of the solution,
+// discount of the link is stored as belief of the opinion, so belief is used as discount of the stored values.
+// Expressions depend on final referral trust only through its discount, so the evaluated values are exact.
+type discountSolutionContext struct {
+	dro       trust.DirectReferralOpinion
+	discounts map[trust.Link]float64 // missing links have zero discount
+}
+
+func (c discountSolutionContext) GetDirectReferralTrust(link trust.Link) opinion.Type {
+	return c.dro[link]
+}
+
+func (c discountSolutionContext) GetFinalReferralTrust(link trust.Link) opinion.Type {
+	if link.From == link.To {
+		return opinion.FullBelief()
+	}
+	return discountOpinion(c.discounts[link])
+}
+
+func (c discountSolutionContext) GetDiscount(o opinion.Type) float64 { return o.B }
+
+// isFlagSet reports whether the flag is set on the command line
+func isFlagSet(name string) (set bool) {
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return
+}
+
+func parseCmdLineParams(args []string) (threshold uint64, inputFileName string, outputFileName string) {
+	thresholdStr := args[0]
 	c, err := strconv.Atoi(thresholdStr)
 	if err != nil {
 		fmt.Printf("invalid threshold value (%v): %v", thresholdStr, err)
@@ -66,92 +400,507 @@ func parseCmdLineParams() (threshold uint64, inputFileName string, outputFileNam
 		os.Exit(1)
 	}
 	threshold = uint64(c)
-	inputFileName = os.Args[2]
-	outputFileName = os.Args[3]
+	inputFileName = args[1]
+	outputFileName = args[2]
 	return
 }
 
-func writeFinalReferralTrustDiscount(outputFileName string, context *equations.DefaultFinalReferralTrustEquationContext) (err error) {
-	outFile, err := os.Create(outputFileName)
-	if err != nil {
-		return
+// sourcesFlag is a list of source nodes, every occurrence of the flag adds a node
+type sourcesFlag []string
+
+func (f *sourcesFlag) String() string {
+	return fmt.Sprint([]string(*f))
+}
+
+func (f *sourcesFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// nodeFormat converts nodes of the files to node identifiers and back:
+// nodes are non-negative integers used as identifiers or string labels registered in the node registry
+type nodeFormat struct {
+	registry *trust.NodeRegistry // nil if nodes are integers
+}
+
+func newNodeFormat(labels bool) nodeFormat {
+	if labels {
+		return nodeFormat{trust.NewNodeRegistry()}
 	}
-	defer func() {
-		if tErr := outFile.Close(); tErr != nil && err == nil {
-			err = tErr
-		}
-	}()
-	of := bufio.NewWriter(outFile)
-	defer func() {
-		if tErr := of.Flush(); tErr != nil && err == nil {
-			err = tErr
-		}
-	}()
+	return nodeFormat{}
+}
 
-	for key, value := range context.FinalReferralTrust {
-		_, err = of.WriteString(fmt.Sprintf("%v\t%v\t%v\n", key.From, key.To, context.GetDiscount(value)))
+// lookup returns identifiers of the known nodes
+func (f nodeFormat) lookup(nodes []string) ([]uint64, error) {
+	if f.registry != nil {
+		return f.registry.IDs(nodes...)
+	}
+	res := make([]uint64, 0, len(nodes))
+	for _, node := range nodes {
+		id, err := strconv.ParseUint(node, 10, 64)
 		if err != nil {
-			return
+			return nil, fmt.Errorf("invalid node (%v): %v", node, err)
 		}
+		res = append(res, id)
 	}
-	return
+	return res, nil
 }
 
-type evidenceFileParser struct {
-	fileName string
+// link returns link formatted as in the files
+func (f nodeFormat) link(link trust.Link) fmt.Stringer {
+	if f.registry != nil {
+		return f.registry.LabeledLink(link)
+	}
+	return link
 }
 
-func (p evidenceFileParser) GetEvidenceIterator() trust.EvidenceIterator {
-	return parseEvidenceFile(p.fileName)
+// format returns nodes of the link formatted as in the files
+func (f nodeFormat) format(link trust.Link) (from, to interface{}) {
+	if f.registry != nil {
+		l := f.registry.LabeledLink(link)
+		return l.From, l.To
+	}
+	return link.From, link.To
 }
 
-func parseEvidenceFile(fileName string) trust.EvidenceIterator {
-	return func(onNext trust.NextEvidenceHandler) (err error) {
-		inputFile, err := os.Open(fileName)
+// parseDiscount parses discount function in the form `name[:parameter]` and returns it with its gradient
+func parseDiscount(s string, threshold uint64) (equations.DiscountFun, equations.DiscountGradientFun, error) {
+	name, paramStr := s, ""
+	if idx := strings.IndexByte(s, ':'); idx >= 0 {
+		name, paramStr = s[:idx], s[idx+1:]
+	}
+
+	param := func(defaultValue float64) (float64, error) {
+		if paramStr == "" {
+			return defaultValue, nil
+		}
+		v, err := strconv.ParseFloat(paramStr, 64)
 		if err != nil {
-			return err
+			return 0, fmt.Errorf("invalid discount parameter (%v): %v", paramStr, err)
 		}
-		defer func() {
-			if tErr := inputFile.Close(); tErr != nil && err == nil {
-				err = tErr
-			}
-		}()
+		return v, nil
+	}
 
-		sc := bufio.NewScanner(bufio.NewReader(inputFile))
-		for sc.Scan() {
-			fields := bytes.Fields(sc.Bytes())
-			if len(fields) != 4 {
-				continue
-			}
+	switch name {
+	case "belief":
+		return equations.BeliefDiscount, equations.BeliefDiscountGradient, nil
+	case "sqrt":
+		return equations.SqrtBeliefDiscount, equations.SqrtBeliefDiscountGradient, nil
+	case "projected":
+		baseRate, err := param(0.5)
+		if err != nil {
+			return nil, nil, err
+		}
+		if baseRate < 0 || baseRate > 1 {
+			return nil, nil, errors.New("base rate must be in [0, 1]")
+		}
+		return equations.ProjectedProbabilityDiscount(baseRate), equations.ProjectedProbabilityDiscountGradient(baseRate), nil
+	case "threshold":
+		beliefThreshold, err := param(0.5)
+		if err != nil {
+			return nil, nil, err
+		}
+		if beliefThreshold < 0 || beliefThreshold >= 1 {
+			return nil, nil, errors.New("belief threshold must be in [0, 1)")
+		}
+		return equations.ThresholdedBeliefDiscount(beliefThreshold), equations.ThresholdedBeliefDiscountGradient(beliefThreshold), nil
+	case "evidence":
+		k, err := param(float64(threshold))
+		if err != nil {
+			return nil, nil, err
+		}
+		if k <= 0 {
+			return nil, nil, errors.New("positive evidence giving 0.5 discount must be positive number")
+		}
+		return equations.EvidenceSaturatingDiscount(threshold, k), equations.EvidenceSaturatingDiscountGradient(threshold, k), nil
+	default:
+		return nil, nil, fmt.Errorf("unknown discount function: %v", name)
+	}
+}
+
+// initializationFun creates initialization of final referral trust of the direct referral trust
+// solved with the given equations options and discount
+type initializationFun func(
+	dro trust.DirectReferralOpinion,
+	eqsOpts []equations.EquationsOption,
+	discount equations.DiscountFun,
+) (equations.Initialization, error)
 
-			from, err := strconv.Atoi(string(fields[0]))
+// parseInitialization parses initialization of final referral trust. Solution file keeps discounts of the final
+// referral trust, they are equal to beliefs of the opinions for belief discount only, so other discounts are rejected.
+func parseInitialization(s, discountName string, nodes nodeFormat) (initializationFun, error) {
+	name, param := s, ""
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		name, param = s[:i], s[i+1:]
+	}
+	if param != "" && name != "solution" {
+		return nil, fmt.Errorf("initialization %v has no parameters", name)
+	}
+
+	switch name {
+	case "belief":
+		return func(trust.DirectReferralOpinion, []equations.EquationsOption, equations.DiscountFun) (equations.Initialization, error) {
+			return equations.FullBeliefInitialization, nil
+		}, nil
+	case "uncertainty":
+		return func(trust.DirectReferralOpinion, []equations.EquationsOption, equations.DiscountFun) (equations.Initialization, error) {
+			return equations.FullUncertaintyInitialization, nil
+		}, nil
+	case "direct":
+		return func(dro trust.DirectReferralOpinion, _ []equations.EquationsOption, _ equations.DiscountFun) (equations.Initialization, error) {
+			return equations.DirectTrustInitialization(dro), nil
+		}, nil
+	case "topological":
+		return func(dro trust.DirectReferralOpinion, eqsOpts []equations.EquationsOption, discount equations.DiscountFun) (equations.Initialization, error) {
+			blocks := equations.CreateFinalReferralTrustEquationBlocks(dro, append(eqsOpts, equations.UseSortedOrder())...)
+			return equations.TopologicalEstimateInitializationOfBlocks(dro, blocks, equations.UseDiscount(discount)), nil
+		}, nil
+	case "solution":
+		if param == "" {
+			return nil, errors.New("initialization solution requires file name: solution:<file>")
+		}
+		if name := strings.SplitN(discountName, ":", 2)[0]; name != "belief" {
+			return nil, fmt.Errorf("initialization solution cannot be used with %v discount, "+
+				"discounts of the solution file are beliefs of the opinions for belief discount only", name)
+		}
+		return func(trust.DirectReferralOpinion, []equations.EquationsOption, equations.DiscountFun) (equations.Initialization, error) {
+			discounts, err := readFinalReferralTrustDiscount(param, nodes)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			if from < 0 {
-				return errors.New("link source must be non-negative number")
+			solution := make(trust.FinalReferralOpinion, len(discounts))
+			for link, d := range discounts {
+				solution[link] = discountOpinion(d)
 			}
-			to, err := strconv.Atoi(string(fields[1]))
-			if err != nil {
-				return err
+			return equations.SolutionInitialization(solution, equations.FullBeliefInitialization), nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown initialization: %v", s)
+	}
+}
+
+func parseAggregation(s string) (trust.AggregationFun, error) {
+	switch s {
+	case "sum":
+		return trust.SumEvidence, nil
+	case "average":
+		return trust.AverageEvidence, nil
+	case "max":
+		return trust.MaxEvidence, nil
+	case "last":
+		return trust.LastEvidence, nil
+	case "reject":
+		return trust.RejectDuplicateEvidence, nil
+	default:
+		return nil, fmt.Errorf("unknown aggregation: %v", s)
+	}
+}
+
+// parseDecay parses decay of evidence in the form `name[:parameter]`, nil is returned if evidence is not decayed
+func parseDecay(s string) (evidence.DecayFun, error) {
+	name, paramStr := s, ""
+	if idx := strings.IndexByte(s, ':'); idx >= 0 {
+		name, paramStr = s[:idx], s[idx+1:]
+	}
+
+	switch name {
+	case "none":
+		return nil, nil
+	case "exponential":
+		halfLife, err := parseAge(paramStr)
+		if err != nil {
+			return nil, err
+		}
+		if halfLife <= 0 {
+			return nil, errors.New("half-life must be positive duration")
+		}
+		return evidence.ExponentialDecay(halfLife), nil
+	case "window":
+		window, err := parseAge(paramStr)
+		if err != nil {
+			return nil, err
+		}
+		return evidence.SlidingWindowDecay(window), nil
+	case "step":
+		var steps []evidence.DecayStep
+		for _, stepStr := range strings.Split(paramStr, ",") {
+			idx := strings.IndexByte(stepStr, '=')
+			if idx < 0 {
+				return nil, fmt.Errorf("invalid decay step (%v): expected <max age>=<weight>", stepStr)
 			}
-			if to < 0 {
-				return errors.New("link destination must be non-negative number")
+			maxAge, err := parseAge(stepStr[:idx])
+			if err != nil {
+				return nil, err
 			}
-			pos, err := strconv.ParseFloat(string(fields[2]), 64)
+			weight, err := strconv.ParseFloat(stepStr[idx+1:], 64)
 			if err != nil {
+				return nil, fmt.Errorf("invalid decay step weight (%v): %v", stepStr[idx+1:], err)
+			}
+			if weight < 0 || weight > 1 {
+				return nil, errors.New("decay step weight must be in [0, 1]")
+			}
+			if len(steps) > 0 && maxAge <= steps[len(steps)-1].MaxAge {
+				return nil, errors.New("decay steps must be sorted by age")
+			}
+			steps = append(steps, evidence.DecayStep{MaxAge: maxAge, Weight: weight})
+		}
+		return evidence.StepCutoffDecay(steps...), nil
+	default:
+		return nil, fmt.Errorf("unknown decay: %v", name)
+	}
+}
+
+// parseAge parses duration, `d` suffix is a number of days
+func parseAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration (%v): %v", s, err)
+		}
+		return time.Duration(days * float64(24*time.Hour)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration (%v): %v", s, err)
+	}
+	return d, nil
+}
+
+func parseUpdateMode(s string) (solver.UpdateMode, error) {
+	switch s {
+	case "gauss-seidel":
+		return solver.GaussSeidelUpdate, nil
+	case "jacobi":
+		return solver.JacobiUpdate, nil
+	default:
+		return 0, fmt.Errorf("unknown update mode: %v", s)
+	}
+}
+
+// solveFun solves final referral trust equations
+type solveFun func(ctx context.Context, opts ...solver.Options) (*solver.Result, error)
+
+// newSolveFun creates equations of the solving method and returns function that solves them
+func newSolveFun(
+	method string,
+	links trust.IterableLinks,
+	frtContext equations.FinalReferralTrustEquationContext,
+	eqsOpts []equations.EquationsOption,
+) (solveFun, error) {
+	switch method {
+	case "fixed-point":
+		eqs := equations.CreateFinalReferralTrustEquations(links, eqsOpts...)
+		return func(ctx context.Context, opts ...solver.Options) (*solver.Result, error) {
+			return solver.SolveFinalReferralTrustEquationsWithContext(ctx, frtContext, eqs, opts...)
+		}, nil
+	case "newton":
+		eqs := equations.CreateFinalReferralTrustEquations(links, eqsOpts...)
+		return func(ctx context.Context, opts ...solver.Options) (*solver.Result, error) {
+			return solver.SolveFinalReferralTrustEquationsNewtonWithContext(ctx, frtContext, eqs, opts...)
+		}, nil
+	case "topological":
+		blocks := equations.CreateFinalReferralTrustEquationBlocks(links, eqsOpts...)
+		return func(ctx context.Context, opts ...solver.Options) (*solver.Result, error) {
+			return solver.SolveFinalReferralTrustEquationBlocksWithContext(ctx, frtContext, blocks, opts...)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown solving method: %v", method)
+	}
+}
+
+// newFinalReferralTrustEquationContext creates context for the given number of workers,
+// it also returns function to get final referral trust values of the context
+func newFinalReferralTrustEquationContext(
+	dro trust.DirectReferralOpinion,
+	workers uint,
+	opts ...equations.ContextOption,
+) (equations.FinalReferralTrustEquationContext, func() trust.FinalReferralOpinion) {
+	if workers > 1 {
+		context := equations.NewConcurrentFinalReferralTrustEquationContext(dro, opts...)
+		return context, context.FinalReferralTrust
+	}
+	context := equations.NewDefaultFinalReferralTrustEquationContext(dro, opts...)
+	return context, func() trust.FinalReferralOpinion { return context.FinalReferralTrust }
+}
+
+func writeFinalReferralTrustDiscount(
+	outputFileName string,
+	fro trust.FinalReferralOpinion,
+	context equations.FinalFunctionalTrustContext,
+	nodes nodeFormat,
+) error {
+	return writeToFile(outputFileName, func(of *bufio.Writer) error {
+		for _, key := range trust.SortedLinks(fro) {
+			value := fro[key]
+			from, to := nodes.format(key)
+			if _, err := of.WriteString(fmt.Sprintf("%v\t%v\t%v\n", from, to, context.GetDiscount(value))); err != nil {
 				return err
 			}
-			neg, err := strconv.ParseFloat(string(fields[3]), 64)
-			if err != nil {
+		}
+		return nil
+	})
+}
+
+func writeFinalFunctionalTrust(outputFileName string, ffo trust.FinalFunctionalOpinion, nodes nodeFormat) error {
+	return writeToFile(outputFileName, func(of *bufio.Writer) error {
+		for _, key := range trust.SortedLinks(ffo) {
+			value := ffo[key]
+			from, to := nodes.format(key)
+			if _, err := of.WriteString(fmt.Sprintf("%v\t%v\t%v\t%v\t%v\n", from, to, value.B, value.D, value.U)); err != nil {
 				return err
 			}
+		}
+		return nil
+	})
+}
 
-			if err := onNext(trust.Link{From: uint64(from), To: uint64(to)}, evidence.New(pos, neg)); err != nil {
+// writeFinalFunctionalTrustProbability writes projected probabilities of final functional trust with the given base rate.
+// Base rate is applied to the solved opinions only: trust equations are solved without base rates.
+func writeFinalFunctionalTrustProbability(outputFileName string, ffo trust.FinalFunctionalOpinion, baseRate float64, nodes nodeFormat) error {
+	return writeToFile(outputFileName, func(of *bufio.Writer) error {
+		for _, key := range trust.SortedLinks(ffo) {
+			value := opinion.WithBaseRate(ffo[key], baseRate)
+			from, to := nodes.format(key)
+			if _, err := of.WriteString(fmt.Sprintf("%v\t%v\t%v\n", from, to, value.ProjectedProbability())); err != nil {
 				return err
 			}
 		}
+		return nil
+	})
+}
+
+// readFinalReferralTrustDiscount reads lines `from to discount` written by writeFinalReferralTrustDiscount
+// (or exported by the Wolfram script), empty lines and lines starting with # are skipped
+func readFinalReferralTrustDiscount(fileName string, nodes nodeFormat) (res map[trust.Link]float64, err error) {
+	inputFile, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if tErr := inputFile.Close(); tErr != nil && err == nil {
+			err = tErr
+		}
+	}()
+
+	res = make(map[trust.Link]float64)
+	sc := bufio.NewScanner(bufio.NewReader(inputFile))
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		link, discount, err := parseFinalReferralTrustDiscount(text, nodes)
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: %v", fileName, line, err)
+		}
+		if _, ok := res[link]; ok {
+			return nil, fmt.Errorf("%v:%v: duplicate discount of link %v", fileName, line, nodes.link(link))
+		}
+		res[link] = discount
+	}
+
+	return res, sc.Err()
+}
+
+// parseFinalReferralTrustDiscount parses line `from to discount` of final referral trust discounts file
+func parseFinalReferralTrustDiscount(text string, nodes nodeFormat) (link trust.Link, discount float64, err error) {
+	fields := strings.Fields(text)
+	if len(fields) != 3 {
+		return link, 0, fmt.Errorf("expected 3 fields `from to discount`, got %v", len(fields))
+	}
+
+	ids, err := nodes.lookup(fields[:2])
+	if err != nil {
+		return link, 0, err
+	}
+	link = trust.Link{From: ids[0], To: ids[1]}
+	if discount, err = strconv.ParseFloat(fields[2], 64); err != nil {
+		return link, 0, fmt.Errorf("invalid discount %q", fields[2])
+	}
+	if !(discount >= 0 && discount <= 1) {
+		return link, 0, fmt.Errorf("discount of link %v must be in [0, 1]", nodes.link(link))
+	}
+	return link, discount, nil
+}
+
+// discountOpinion returns opinion with belief equal to the discount saved by writeFinalReferralTrustDiscount,
+// its belief discount is the saved discount
+func discountOpinion(d float64) opinion.Type {
+	return opinion.New(d, 0, 1-d)
+}
+
+func writeToFile(outputFileName string, write func(of *bufio.Writer) error) (err error) {
+	outFile, err := os.Create(outputFileName)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if tErr := outFile.Close(); tErr != nil && err == nil {
+			err = tErr
+		}
+	}()
+	of := bufio.NewWriter(outFile)
+	defer func() {
+		if tErr := of.Flush(); tErr != nil && err == nil {
+			err = tErr
+		}
+	}()
 
-		return sc.Err()
+	err = write(of)
+	return
+}
+
+// evidenceReader reads evidence files
+type evidenceReader struct {
+	format      evidenceio.Format
+	nodes       nodeFormat
+	aggregate   trust.AggregationFun
+	decay       evidence.DecayFun // nil if evidence is not decayed
+	reference   time.Time         // reference time of the decay
+	maxErrors   uint              // reading fails after this number of invalid records (0 for no limit)
+	skipInvalid bool              // invalid records are skipped, otherwise reading fails after all invalid records are reported
+}
+
+// read reads evidence of the file, evidences of the same link are aggregated after decay.
+// Every invalid record is reported to the log as `file:line: reason`.
+func (r evidenceReader) read(fileName string) (map[trust.Link]evidence.Type, error) {
+	var invalid uint
+	opts := []evidenceio.Option{
+		evidenceio.UseNodeParser(evidenceio.IntegerNodes),
+		evidenceio.UseInvalidRecordHandler(func(err *evidenceio.ParseError) error {
+			log.Println(err)
+			invalid++
+			if r.maxErrors > 0 && invalid >= r.maxErrors {
+				return fmt.Errorf("%v: too many invalid records (%v)", fileName, invalid)
+			}
+			return nil
+		}),
+	}
+	if r.nodes.registry != nil {
+		opts[0] = evidenceio.UseNodeParser(evidenceio.LabeledNodes(r.nodes.registry))
+	}
+	if r.format != evidenceio.AutoFormat {
+		opts = append(opts, evidenceio.UseFormat(r.format))
+	}
+
+	file := evidenceio.NewFile(fileName, opts...)
+	var evidences trust.IterableEvidences = file
+	if r.decay != nil {
+		evidences = trust.DecayedEvidences(file, r.decay, r.reference)
+	}
+
+	res, err := make(trust.DirectReferralEvidence).FromIterableEvidences(evidences, r.aggregate)
+	if err != nil {
+		return nil, err
+	}
+	if invalid > 0 {
+		if !r.skipInvalid {
+			return nil, fmt.Errorf("%v: %v invalid records", fileName, invalid)
+		}
+		log.Printf("%v: %v invalid records are skipped\n", fileName, invalid)
 	}
+	return res, nil
 }
@@ -0,0 +1,109 @@
+package opinion
+
+import (
+	"fmt"
+
+	"github.com/dimchansky/ebsl-go/evidence"
+)
+
+// DefaultBaseRate is base rate of uniform prior
+const DefaultBaseRate = 0.5
+
+// BaseRated is an opinion with base rate A in [0, 1]: prior probability of the proposition used in absence of evidence.
+// Trust equations are solved for opinions without base rates, base rate can be applied to the solved opinions.
+type BaseRated struct {
+	B, D, U, A float64
+}
+
+// String implements fmt.Stringer
+func (x *BaseRated) String() string {
+	return fmt.Sprintf("{B: %v, D: %v, U: %v, A: %v}", x.B, x.D, x.U, x.A)
+}
+
+// NewBaseRated creates new instance of opinion with base rate
+func NewBaseRated(b, d, u, a float64) BaseRated {
+	return BaseRated{B: b, D: d, U: u, A: a}
+}
+
+// WithBaseRate returns opinion `x` with base rate `a`
+func WithBaseRate(x Type, a float64) BaseRated {
+	return BaseRated{B: x.B, D: x.D, U: x.U, A: a}
+}
+
+// Opinion returns opinion without base rate
+func (x *BaseRated) Opinion() Type {
+	return Type{B: x.B, D: x.D, U: x.U}
+}
+
+// ProjectedProbability returns probability b + a·u of the proposition
+func (x *BaseRated) ProjectedProbability() float64 {
+	return x.B + x.A*x.U
+}
+
+// FromEvidenceWithBaseRate converts evidence to opinion with base rate `a` using prior weight `w` (must be positive number).
+// Projected probability of the opinion is the mean of Beta(P + w·a, N + w·(1-a)) distribution.
+func FromEvidenceWithBaseRate(w, a float64, e evidence.Type) (t BaseRated) {
+	t.A = a
+	t.FromEvidence(w, e)
+	return
+}
+
+// FromEvidence converts evidence and updates opinion using prior weight `w` (must be positive number),
+// base rate is not changed. Method returns updated value.
+func (x *BaseRated) FromEvidence(w float64, e evidence.Type) *BaseRated {
+	k := w + e.P + e.N
+	x.B = e.P / k
+	x.D = e.N / k
+	x.U = w / k
+	return x
+}
+
+// ToEvidence converts opinion to evidence using prior weight `w` (must be positive number).
+func (x *BaseRated) ToEvidence(w float64) evidence.Type {
+	return evidence.New(w*x.B/x.U, w*x.D/x.U)
+}
+
+// Mul sets x to the scalar multiplication α·x (discount) and returns x, base rate is not changed.
+func (x *BaseRated) Mul(α float64) *BaseRated {
+	o := x.Opinion()
+	x.set(o.Mul(α))
+	return x
+}
+
+// Plus sets x to the x⊕y (consensus) and returns x.
+// Base rate of the result is the average of base rates weighted by the evidence of the other opinion,
+// it is the plain average when both opinions are full uncertainty or dogmatic.
+func (x *BaseRated) Plus(y *BaseRated) *BaseRated {
+	x.A = consensusBaseRate(x.A, x.U, y.A, y.U)
+	o, yo := x.Opinion(), y.Opinion()
+	x.set(o.Plus(&yo))
+	return x
+}
+
+// PlusMul sets x to the x⊕(α·y) and returns x.
+func (x *BaseRated) PlusMul(α float64, y *BaseRated) *BaseRated {
+	if α == 0 {
+		return x
+	}
+	z := *y
+	return x.Plus(z.Mul(α))
+}
+
+// Not sets x to the ¬x (belief and disbelief are swapped, base rate is complemented) and returns x.
+func (x *BaseRated) Not() *BaseRated {
+	x.B, x.D = x.D, x.B
+	x.A = 1 - x.A
+	return x
+}
+
+func (x *BaseRated) set(o *Type) {
+	x.B, x.D, x.U = o.B, o.D, o.U
+}
+
+func consensusBaseRate(xa, xu, ya, yu float64) float64 {
+	k := xu + yu - 2*xu*yu
+	if k == 0 {
+		return (xa + ya) / 2
+	}
+	return (xa*yu + ya*xu - (xa+ya)*xu*yu) / k
+}
@@ -0,0 +1,89 @@
+package opinion_test
+
+import (
+	"testing"
+
+	"github.com/dimchansky/ebsl-go/evidence"
+	"github.com/dimchansky/ebsl-go/opinion"
+	"github.com/go-test/deep"
+)
+
+func TestBaseRated_FromEvidence(t *testing.T) {
+	e := evidence.New(6, 2)
+
+	x := opinion.FromEvidenceWithBaseRate(2, opinion.DefaultBaseRate, e)
+	if diff := deep.Equal(x.ProjectedProbability(), e.Mean()); diff != nil {
+		t.Errorf("ProjectedProbability of uniform prior: %v", diff)
+	}
+	if diff := deep.Equal(x.Opinion(), opinion.FromEvidence(2, e)); diff != nil {
+		t.Errorf("Opinion: %v", diff)
+	}
+
+	// skeptical prior: newcomer without evidence has projected probability 0.2
+	x = opinion.FromEvidenceWithBaseRate(10, 0.2, evidence.New(0, 0))
+	if diff := deep.Equal(x, opinion.NewBaseRated(0, 0, 1, 0.2)); diff != nil {
+		t.Errorf("FromEvidence without evidence: %v", diff)
+	}
+	if diff := deep.Equal(x.ProjectedProbability(), 0.2); diff != nil {
+		t.Errorf("ProjectedProbability without evidence: %v", diff)
+	}
+
+	x = opinion.FromEvidenceWithBaseRate(10, 0.2, e)
+	if diff := deep.Equal(x.ProjectedProbability(), (6+10*0.2)/(10+6+2)); diff != nil {
+		t.Errorf("ProjectedProbability: %v", diff)
+	}
+	if diff := deep.Equal(x.ToEvidence(10), e); diff != nil {
+		t.Errorf("ToEvidence: %v", diff)
+	}
+}
+
+func TestBaseRated_Plus(t *testing.T) {
+	tests := []struct {
+		name string
+		x, y opinion.BaseRated
+		want opinion.BaseRated
+	}{
+		{"different base rates",
+			opinion.NewBaseRated(0.5, 0, 0.5, 0.2), opinion.NewBaseRated(0, 0.5, 0.5, 0.6),
+			opinion.NewBaseRated(1.0/3, 1.0/3, 1.0/3, 0.4)},
+		{"base rate of full uncertainty is ignored",
+			opinion.NewBaseRated(0.5, 0, 0.5, 0.2), opinion.NewBaseRated(0, 0, 1, 0.6),
+			opinion.NewBaseRated(0.5, 0, 0.5, 0.2)},
+		{"both are full uncertainty",
+			opinion.NewBaseRated(0, 0, 1, 0.2), opinion.NewBaseRated(0, 0, 1, 0.6),
+			opinion.NewBaseRated(0, 0, 1, 0.4)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := *tt.x.Plus(&tt.y)
+
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Errorf("Plus: %v", diff)
+			}
+		})
+	}
+}
+
+func TestBaseRated_PlusMul(t *testing.T) {
+	x := opinion.NewBaseRated(0.5, 0, 0.5, 0.2)
+	y := opinion.NewBaseRated(0, 0.5, 0.5, 0.6)
+
+	xo, yo := x.Opinion(), y.Opinion()
+
+	got := *x.PlusMul(0.5, &y)
+	want := opinion.WithBaseRate(*xo.PlusMul(0.5, &yo), 1.0/3)
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("PlusMul: %v", diff)
+	}
+}
+
+func TestBaseRated_Not(t *testing.T) {
+	x := opinion.NewBaseRated(0.5, 0.2, 0.3, 0.25)
+
+	got := *x.Not()
+	want := opinion.NewBaseRated(0.2, 0.5, 0.3, 0.75)
+
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("Not: %v", diff)
+	}
+}
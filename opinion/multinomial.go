@@ -0,0 +1,129 @@
+package opinion
+
+import (
+	"fmt"
+
+	"github.com/dimchansky/ebsl-go/evidence"
+)
+
+// Multinomial is an opinion about a proposition with several mutually exclusive outcomes:
+// belief mass B[i] per outcome and uncertainty U, the masses sum to 1.
+// Missing outcomes of an opinion with shorter B have no belief.
+//
+// Methods updating the opinion never modify elements of B in place, so opinions can share belief slices.
+type Multinomial struct {
+	B []float64
+	U float64
+}
+
+// String implements fmt.Stringer
+func (x *Multinomial) String() string {
+	return fmt.Sprintf("{B: %v, U: %v}", x.B, x.U)
+}
+
+// NewMultinomial creates new instance of multinomial opinion
+func NewMultinomial(u float64, b ...float64) Multinomial {
+	return Multinomial{B: b, U: u}
+}
+
+// FullUncertaintyMultinomial returns full uncertainty multinomial opinion about `outcomes` outcomes
+func FullUncertaintyMultinomial(outcomes int) Multinomial {
+	return Multinomial{B: make([]float64, outcomes), U: 1}
+}
+
+// ToMultinomial returns binomial opinion as multinomial opinion: belief is the mass of the first outcome
+// and disbelief is the mass of the second outcome
+func (x *Type) ToMultinomial() Multinomial {
+	return Multinomial{B: []float64{x.B, x.D}, U: x.U}
+}
+
+// MultinomialFromEvidence converts evidence to opinion using `c` as soft threshold/"unit" of evidence (must be positive number).
+func MultinomialFromEvidence(c uint64, e evidence.Multinomial) (t Multinomial) {
+	t.FromEvidence(c, e)
+	return
+}
+
+// FromEvidence converts evidence and updates opinion using `c` as soft threshold/"unit" of evidence (must be positive number).
+// Method returns updated value.
+func (x *Multinomial) FromEvidence(c uint64, e evidence.Multinomial) *Multinomial {
+	k := float64(c) + e.Total()
+	b := make([]float64, len(e))
+	for i, v := range e {
+		b[i] = v / k
+	}
+	x.B = b
+	x.U = float64(c) / k
+	return x
+}
+
+// ToEvidence converts opinion to evidence using `c` as soft threshold/"unit" of evidence (must be positive number).
+func (x *Multinomial) ToEvidence(c uint64) evidence.Multinomial {
+	res := make(evidence.Multinomial, len(x.B))
+	for i, b := range x.B {
+		res[i] = float64(c) * b / x.U
+	}
+	return res
+}
+
+// Outcomes returns number of outcomes of the opinion
+func (x *Multinomial) Outcomes() int {
+	return len(x.B)
+}
+
+// Outcome returns binomial opinion about the given outcome: belief is the mass of the outcome
+// and disbelief is the mass of other outcomes
+func (x *Multinomial) Outcome(i int) Type {
+	var b, d float64
+	for j, v := range x.B {
+		if j == i {
+			b = v
+		} else {
+			d += v
+		}
+	}
+	return Type{B: b, D: d, U: x.U}
+}
+
+// Mul sets x to the scalar multiplication α·x and returns x.
+func (x *Multinomial) Mul(α float64) *Multinomial {
+	k := x.U
+	for _, v := range x.B {
+		k += α * v
+	}
+	b := make([]float64, len(x.B))
+	for i, v := range x.B {
+		b[i] = α * v / k
+	}
+	x.B = b
+	x.U = x.U / k
+	return x
+}
+
+// Plus sets x to the x⊕y and returns x.
+func (x *Multinomial) Plus(y *Multinomial) *Multinomial {
+	return x.PlusMul(1, y)
+}
+
+// PlusMul sets x to the x⊕(α·y) and returns x.
+func (x *Multinomial) PlusMul(α float64, y *Multinomial) *Multinomial {
+	if α == 0 {
+		return x
+	}
+	xu := x.U
+	yu := y.U
+	k := yu + α*xu*(1-yu)
+	n := len(x.B)
+	if len(y.B) > n {
+		n = len(y.B)
+	}
+	b := make([]float64, n)
+	for i, v := range x.B {
+		b[i] = yu * v / k
+	}
+	for i, v := range y.B {
+		b[i] += α * xu * v / k
+	}
+	x.B = b
+	x.U = xu * yu / k
+	return x
+}
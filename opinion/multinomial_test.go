@@ -0,0 +1,85 @@
+package opinion_test
+
+import (
+	"testing"
+
+	"github.com/dimchansky/ebsl-go/evidence"
+	"github.com/dimchansky/ebsl-go/opinion"
+	"github.com/go-test/deep"
+)
+
+func TestMultinomial_FromEvidence(t *testing.T) {
+	e := evidence.NewMultinomial(5, 2, 1)
+
+	x := opinion.MultinomialFromEvidence(2, e)
+	if diff := deep.Equal(x, opinion.NewMultinomial(0.2, 0.5, 0.2, 0.1)); diff != nil {
+		t.Errorf("MultinomialFromEvidence: %v", diff)
+	}
+	if diff := deep.Equal(x.ToEvidence(2), e); diff != nil {
+		t.Errorf("ToEvidence: %v", diff)
+	}
+	if diff := deep.Equal(x.Outcome(1), opinion.New(0.2, 0.6, 0.2)); diff != nil {
+		t.Errorf("Outcome: %v", diff)
+	}
+
+	// binomial evidence is multinomial evidence of two outcomes
+	b := evidence.New(6, 2)
+	bo := opinion.FromEvidence(2, b)
+	if diff := deep.Equal(opinion.MultinomialFromEvidence(2, b.ToMultinomial()), bo.ToMultinomial()); diff != nil {
+		t.Errorf("MultinomialFromEvidence of binomial evidence: %v", diff)
+	}
+}
+
+func TestMultinomial_BinomialOperations(t *testing.T) {
+	const α = 0.4
+
+	tests := []struct {
+		name     string
+		binomial func(x, y opinion.Type) opinion.Type
+		got      func(x, y opinion.Multinomial) opinion.Multinomial
+	}{
+		{"Mul",
+			func(x, y opinion.Type) opinion.Type { return *x.Mul(α) },
+			func(x, y opinion.Multinomial) opinion.Multinomial { return *x.Mul(α) }},
+		{"Plus",
+			func(x, y opinion.Type) opinion.Type { return *x.Plus(&y) },
+			func(x, y opinion.Multinomial) opinion.Multinomial { return *x.Plus(&y) }},
+		{"PlusMul",
+			func(x, y opinion.Type) opinion.Type { return *x.PlusMul(α, &y) },
+			func(x, y opinion.Multinomial) opinion.Multinomial { return *x.PlusMul(α, &y) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x, y := opinion.New(0.5, 0.2, 0.3), opinion.New(0.1, 0.6, 0.3)
+
+			got := tt.got(x.ToMultinomial(), y.ToMultinomial())
+			want := tt.binomial(x, y)
+
+			if diff := deep.Equal(got, want.ToMultinomial()); diff != nil {
+				t.Errorf("%v: %v", tt.name, diff)
+			}
+		})
+	}
+}
+
+func TestMultinomial_Plus(t *testing.T) {
+	x := opinion.NewMultinomial(0.5, 0.5)
+	y := opinion.NewMultinomial(0.5, 0, 0.25, 0.25)
+	b := y.B
+
+	got := *x.Plus(&y)
+	want := opinion.NewMultinomial(1.0/3, 1.0/3, 1.0/6, 1.0/6)
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("Plus: %v", diff)
+	}
+
+	u := opinion.FullUncertaintyMultinomial(2)
+	got = *got.PlusMul(1, u.Mul(0.5))
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("Plus of full uncertainty: %v", diff)
+	}
+
+	if diff := deep.Equal(b, []float64{0, 0.25, 0.25}); diff != nil {
+		t.Errorf("belief of the operand is modified: %v", diff)
+	}
+}
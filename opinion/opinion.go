@@ -37,6 +37,12 @@ func (x *Type) FromEvidence(c uint64, e evidence.Type) *Type {
 	return x
 }
 
+// ToEvidence converts opinion to evidence using `c` as soft threshold/"unit" of evidence (must be positive number).
+// It is the inverse of FromEvidence: evidence of the opinion without uncertainty is infinite.
+func ToEvidence(c uint64, x Type) evidence.Type {
+	return x.ToEvidence(c)
+}
+
 // ToEvidence converts opinion to evidence using `c` as soft threshold/"unit" of evidence (must be positive number).
 func (x *Type) ToEvidence(c uint64) evidence.Type {
 	p := float64(c) * x.B / x.U
@@ -95,3 +101,31 @@ func (x *Type) PlusMul(α float64, y *Type) *Type {
 	x.U = xu * yu / k
 	return x
 }
+
+// Not sets x to the ¬x (belief and disbelief are swapped) and returns x.
+func (x *Type) Not() *Type {
+	x.B, x.D = x.D, x.B
+	return x
+}
+
+// Dot sets x to the x⊙y and returns x, where x is an opinion about implication p⇒q and y is an opinion about p.
+// The result is an opinion about q: belief in q is supported only when both p⇒q and p are believed,
+// the rest of the mass is uncertainty.
+func (x *Type) Dot(y *Type) *Type {
+	b := x.B * y.B
+	x.B = b
+	x.D = 0
+	x.U = 1 - b
+	return x
+}
+
+// Contradot sets x to the x⊖y and returns x, where x is an opinion about implication p⇒q and y is an opinion about q.
+// The result is an opinion about p: disbelief in p is supported only when p⇒q is believed and q is disbelieved,
+// the rest of the mass is uncertainty.
+func (x *Type) Contradot(y *Type) *Type {
+	d := x.B * y.D
+	x.B = 0
+	x.D = d
+	x.U = 1 - d
+	return x
+}
@@ -0,0 +1,78 @@
+package opinion_test
+
+import (
+	"testing"
+
+	"github.com/dimchansky/ebsl-go/evidence"
+	"github.com/dimchansky/ebsl-go/opinion"
+	"github.com/go-test/deep"
+)
+
+func TestType_Not(t *testing.T) {
+	x := opinion.New(0.5, 0.2, 0.3)
+
+	got := *x.Not()
+	want := opinion.New(0.2, 0.5, 0.3)
+
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("Not: %v", diff)
+	}
+}
+
+func TestType_Dot(t *testing.T) {
+	tests := []struct {
+		name string
+		imp  opinion.Type
+		ant  opinion.Type
+		want opinion.Type
+	}{
+		{"full belief in both", opinion.FullBelief(), opinion.FullBelief(), opinion.FullBelief()},
+		{"disbelief in implication", opinion.FullDisbelief(), opinion.FullBelief(), opinion.FullUncertainty()},
+		{"disbelief in antecedent", opinion.FullBelief(), opinion.FullDisbelief(), opinion.FullUncertainty()},
+		{"partial", opinion.New(0.5, 0.25, 0.25), opinion.New(0.5, 0.5, 0), opinion.New(0.25, 0, 0.75)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := *tt.imp.Dot(&tt.ant)
+
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Errorf("Dot: %v", diff)
+			}
+		})
+	}
+}
+
+func TestType_Contradot(t *testing.T) {
+	tests := []struct {
+		name string
+		imp  opinion.Type
+		cons opinion.Type
+		want opinion.Type
+	}{
+		{"belief in implication, disbelief in consequent", opinion.FullBelief(), opinion.FullDisbelief(), opinion.FullDisbelief()},
+		{"belief in consequent", opinion.FullBelief(), opinion.FullBelief(), opinion.FullUncertainty()},
+		{"disbelief in implication", opinion.FullDisbelief(), opinion.FullDisbelief(), opinion.FullUncertainty()},
+		{"partial", opinion.New(0.5, 0.25, 0.25), opinion.New(0.5, 0.5, 0), opinion.New(0, 0.25, 0.75)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := *tt.imp.Contradot(&tt.cons)
+
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Errorf("Contradot: %v", diff)
+			}
+		})
+	}
+}
+
+func TestToEvidence(t *testing.T) {
+	e := evidence.New(6, 2)
+
+	x := opinion.FromEvidence(2, e)
+	if diff := deep.Equal(x, opinion.New(0.6, 0.2, 0.2)); diff != nil {
+		t.Errorf("FromEvidence: %v", diff)
+	}
+	if diff := deep.Equal(opinion.ToEvidence(2, x), e); diff != nil {
+		t.Errorf("ToEvidence: %v", diff)
+	}
+}
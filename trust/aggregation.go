@@ -0,0 +1,85 @@
+package trust
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/dimchansky/ebsl-go/evidence"
+)
+
+// DuplicateEvidenceError is returned by RejectDuplicateEvidence when there are several evidences of the same link
+type DuplicateEvidenceError struct {
+	Link Link
+}
+
+func (e *DuplicateEvidenceError) Error() string {
+	return fmt.Sprintf("trust: duplicate evidence of link %v", e.Link)
+}
+
+// AggregationFun combines evidence `ev` of the link with evidence `aggregated` of `count` previous evidences of the link
+type AggregationFun func(link Link, aggregated evidence.Type, count int, ev evidence.Type) (evidence.Type, error)
+
+// SumEvidence sums positive and negative evidence of all evidences of the link (cumulative fusion),
+// it is used when every evidence is a separate event
+func SumEvidence(_ Link, aggregated evidence.Type, _ int, ev evidence.Type) (evidence.Type, error) {
+	return aggregated.Add(ev), nil
+}
+
+// AverageEvidence averages positive and negative evidence of all evidences of the link
+func AverageEvidence(_ Link, aggregated evidence.Type, count int, ev evidence.Type) (evidence.Type, error) {
+	n := float64(count + 1)
+	return evidence.New(aggregated.P+(ev.P-aggregated.P)/n, aggregated.N+(ev.N-aggregated.N)/n), nil
+}
+
+// MaxEvidence takes the largest positive and the largest negative evidence of all evidences of the link
+func MaxEvidence(_ Link, aggregated evidence.Type, _ int, ev evidence.Type) (evidence.Type, error) {
+	return evidence.New(math.Max(aggregated.P, ev.P), math.Max(aggregated.N, ev.N)), nil
+}
+
+// LastEvidence takes the last evidence of the link
+func LastEvidence(_ Link, _ evidence.Type, _ int, ev evidence.Type) (evidence.Type, error) {
+	return ev, nil
+}
+
+// RejectDuplicateEvidence returns DuplicateEvidenceError if there are several evidences of the link
+func RejectDuplicateEvidence(link Link, _ evidence.Type, _ int, _ evidence.Type) (evidence.Type, error) {
+	return evidence.Type{}, &DuplicateEvidenceError{Link: link}
+}
+
+// AggregateEvidences calls `onNext` for every link of the evidences with evidence aggregated from all evidences of the link,
+// links are handled in order of their first evidence
+func AggregateEvidences(evidences IterableEvidences, aggregate AggregationFun, onNext NextEvidenceHandler) error {
+	type aggregation struct {
+		ev    evidence.Type
+		count int
+	}
+
+	var links []Link
+	aggregated := make(map[Link]*aggregation)
+	foreachEvidence := evidences.GetEvidenceIterator()
+	if err := foreachEvidence(func(link Link, ev evidence.Type) error {
+		a, ok := aggregated[link]
+		if !ok {
+			links = append(links, link)
+			aggregated[link] = &aggregation{ev: ev, count: 1}
+			return nil
+		}
+
+		res, err := aggregate(link, a.ev, a.count, ev)
+		if err != nil {
+			return err
+		}
+		a.ev = res
+		a.count++
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		if err := onNext(link, aggregated[link].ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
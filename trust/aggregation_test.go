@@ -0,0 +1,148 @@
+package trust_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dimchansky/ebsl-go/evidence"
+	"github.com/dimchansky/ebsl-go/opinion"
+	"github.com/dimchansky/ebsl-go/trust"
+	"github.com/go-test/deep"
+)
+
+// evidenceRecords are evidences of the links in the given order, links can be duplicated
+type evidenceRecords []struct {
+	link trust.Link
+	ev   evidence.Type
+}
+
+func (r evidenceRecords) GetEvidenceIterator() trust.EvidenceIterator {
+	return func(onNext trust.NextEvidenceHandler) error {
+		for _, record := range r {
+			if err := onNext(record.link, record.ev); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestDirectReferralEvidenceFromIterableEvidences(t *testing.T) {
+	l12, l21 := trust.Link{From: 1, To: 2}, trust.Link{From: 2, To: 1}
+	records := evidenceRecords{
+		{l12, evidence.New(1, 0)},
+		{l21, evidence.New(5, 5)},
+		{l12, evidence.New(4, 3)},
+		{l12, evidence.New(1, 6)},
+	}
+
+	tests := []struct {
+		name      string
+		aggregate trust.AggregationFun
+		want      trust.DirectReferralEvidence
+	}{
+		{"sum", trust.SumEvidence, trust.DirectReferralEvidence{l12: evidence.New(6, 9), l21: evidence.New(5, 5)}},
+		{"average", trust.AverageEvidence, trust.DirectReferralEvidence{l12: evidence.New(2, 3), l21: evidence.New(5, 5)}},
+		{"max", trust.MaxEvidence, trust.DirectReferralEvidence{l12: evidence.New(4, 6), l21: evidence.New(5, 5)}},
+		{"last", trust.LastEvidence, trust.DirectReferralEvidence{l12: evidence.New(1, 6), l21: evidence.New(5, 5)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := make(trust.DirectReferralEvidence).FromIterableEvidences(records, tt.aggregate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		_, err := make(trust.DirectReferralEvidence).FromIterableEvidences(records, trust.RejectDuplicateEvidence)
+		var duplicateErr *trust.DuplicateEvidenceError
+		if !errors.As(err, &duplicateErr) || duplicateErr.Link != l12 {
+			t.Errorf("got error %v, want duplicate evidence error of link %v", err, l12)
+		}
+	})
+
+	t.Run("opinion", func(t *testing.T) {
+		got := make(trust.DirectReferralOpinion).FromIterableEvidences(records, 2)
+		want := trust.DirectReferralOpinion{
+			l12: opinion.FromEvidence(2, evidence.New(1, 6)),
+			l21: opinion.FromEvidence(2, evidence.New(5, 5)),
+		}
+		if diff := deep.Equal(got, want); diff != nil {
+			t.Error(diff)
+		}
+
+		got, err := make(trust.DirectReferralOpinion).FromAggregatedEvidences(records, 2, trust.SumEvidence)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = trust.DirectReferralOpinion{
+			l12: opinion.FromEvidence(2, evidence.New(6, 9)),
+			l21: opinion.FromEvidence(2, evidence.New(5, 5)),
+		}
+		if diff := deep.Equal(got, want); diff != nil {
+			t.Errorf("FromAggregatedEvidences: %v", diff)
+		}
+	})
+}
+
+// timedEvidenceRecords are evidences of the links with timestamps
+type timedEvidenceRecords []struct {
+	link      trust.Link
+	ev        evidence.Type
+	timestamp time.Time
+}
+
+func (r timedEvidenceRecords) GetTimedEvidenceIterator() trust.TimedEvidenceIterator {
+	return func(onNext trust.NextTimedEvidenceHandler) error {
+		for _, record := range r {
+			if err := onNext(record.link, record.ev, record.timestamp); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestDecayedEvidences(t *testing.T) {
+	const day = 24 * time.Hour
+	reference := time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC)
+	l12, l21 := trust.Link{From: 1, To: 2}, trust.Link{From: 2, To: 1}
+	records := timedEvidenceRecords{
+		{l12, evidence.New(8, 0), reference.Add(-20 * day)},
+		{l12, evidence.New(0, 4), reference.Add(-10 * day)},
+		{l12, evidence.New(2, 2), reference.Add(day)}, // future evidence is not decayed
+		{l21, evidence.New(4, 4), time.Time{}},        // evidence without timestamp is not decayed
+	}
+
+	tests := []struct {
+		name  string
+		decay evidence.DecayFun
+		want  trust.DirectReferralEvidence
+	}{
+		{"none", evidence.NoDecay, trust.DirectReferralEvidence{l12: evidence.New(10, 6), l21: evidence.New(4, 4)}},
+		{"exponential", evidence.ExponentialDecay(10 * day), trust.DirectReferralEvidence{l12: evidence.New(4, 4), l21: evidence.New(4, 4)}},
+		{"sliding window", evidence.SlidingWindowDecay(15 * day), trust.DirectReferralEvidence{l12: evidence.New(2, 6), l21: evidence.New(4, 4)}},
+		{"step cutoff", evidence.StepCutoffDecay(
+			evidence.DecayStep{MaxAge: 5 * day, Weight: 1},
+			evidence.DecayStep{MaxAge: 30 * day, Weight: 0.5},
+		), trust.DirectReferralEvidence{l12: evidence.New(6, 4), l21: evidence.New(4, 4)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := make(trust.DirectReferralEvidence).FromIterableEvidences(
+				trust.DecayedEvidences(records, tt.decay, reference), trust.SumEvidence)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
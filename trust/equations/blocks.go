@@ -0,0 +1,152 @@
+package equations
+
+import (
+	"github.com/dimchansky/ebsl-go/trust"
+)
+
+// FinalReferralTrustEquationBlock is a set of final referral trust equations of the same source node
+// which form strongly connected component of the dependency graph of equations.
+type FinalReferralTrustEquationBlock struct {
+	// Equations of the block
+	Equations FinalReferralTrustEquations
+	// Cyclic is true if equations of the block depend on each other and have to be solved iteratively,
+	// otherwise the block has a single equation that depends only on equations of the preceding blocks.
+	Cyclic bool
+}
+
+// NextFinalReferralTrustEquationBlockHandler handles next block of final referral trust equations and returns error
+type NextFinalReferralTrustEquationBlockHandler func(*FinalReferralTrustEquationBlock) error
+
+// FinalReferralTrustEquationBlockIterator used as `foreach` to handle all blocks of final referral trust equations
+type FinalReferralTrustEquationBlockIterator func(NextFinalReferralTrustEquationBlockHandler) error
+
+// IterableFinalReferralTrustEquationBlocks allows to iterate over all blocks of final referral trust equations
+type IterableFinalReferralTrustEquationBlocks interface {
+	GetFinalReferralTrustEquationBlockIterator() FinalReferralTrustEquationBlockIterator
+}
+
+// CreateFinalReferralTrustEquationBlocks creates equations for the final referral trust split into blocks.
+// Equation R[i, j] depends on R[i, k] if there is a link from k to j, so for every source node i blocks are strongly
+// connected components of the graph of nodes reachable from i (i itself excluded). Blocks of the source node are
+// iterated in topological order: block is iterated after all blocks it depends on.
+func CreateFinalReferralTrustEquationBlocks(links trust.IterableLinks, opts ...EquationsOption) IterableFinalReferralTrustEquationBlocks {
+	return newIterableEquations(links, opts)
+}
+
+func (ec iterableEquations) GetFinalReferralTrustEquationBlockIterator() FinalReferralTrustEquationBlockIterator {
+	return func(onNext NextFinalReferralTrustEquationBlockHandler) error {
+		return ec.forEachSource(func(from uint64, reachable []uint64, isReachable map[uint64]bool) error {
+			components := stronglyConnectedComponents(reachable, func(node uint64, f func(uint64)) {
+				ec.sourceGraph.forEachAdjacent(node, func(sinkNode uint64) {
+					if sinkNode != from && sinkNode != node { // R[from, from] is not an equation, A[k, k] is not a term
+						f(sinkNode)
+					}
+				})
+			})
+
+			for _, component := range components {
+				ec.sinkGraph.sort(component)
+
+				block := &FinalReferralTrustEquationBlock{Cyclic: len(component) > 1}
+				for _, to := range component {
+					if eq := ec.createEquation(from, to, isReachable); eq != nil {
+						block.Equations = append(block.Equations, eq)
+					}
+				}
+
+				if len(block.Equations) > 0 {
+					if err := onNext(block); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// stronglyConnectedComponents finds strongly connected components of the graph of `nodes` using Tarjan's algorithm
+// and returns them in topological order: if there is an edge from node u to node v of other component, component of u
+// precedes component of v. All adjacent nodes must be in `nodes`.
+func stronglyConnectedComponents(nodes []uint64, forEachAdjacent func(node uint64, f func(uint64))) [][]uint64 {
+	type frame struct {
+		node     uint64
+		adjacent []uint64
+		next     int // index of the next adjacent node to visit
+	}
+
+	var (
+		counter    int
+		index      = make(map[uint64]int, len(nodes))
+		lowLink    = make(map[uint64]int, len(nodes))
+		onStack    = make(map[uint64]bool, len(nodes))
+		stack      []uint64 // nodes of the components not completed yet
+		callStack  []frame  // replaces recursion of the algorithm
+		components [][]uint64
+	)
+
+	visit := func(node uint64) {
+		index[node] = counter
+		lowLink[node] = counter
+		counter++
+		stack = append(stack, node)
+		onStack[node] = true
+
+		var adjacent []uint64
+		forEachAdjacent(node, func(v uint64) { adjacent = append(adjacent, v) })
+		callStack = append(callStack, frame{node: node, adjacent: adjacent})
+	}
+
+	for _, root := range nodes {
+		if _, ok := index[root]; ok {
+			continue
+		}
+
+		visit(root)
+		for len(callStack) > 0 {
+			f := &callStack[len(callStack)-1]
+			node := f.node
+
+			if f.next < len(f.adjacent) {
+				v := f.adjacent[f.next]
+				f.next++
+				if _, ok := index[v]; !ok {
+					visit(v)
+				} else if onStack[v] && index[v] < lowLink[node] {
+					lowLink[node] = index[v]
+				}
+				continue
+			}
+
+			// all adjacent nodes are visited
+			if lowLink[node] == index[node] {
+				var component []uint64
+				for {
+					n := len(stack) - 1
+					v := stack[n]
+					stack = stack[:n]
+					onStack[v] = false
+					component = append(component, v)
+					if v == node {
+						break
+					}
+				}
+				components = append(components, component)
+			}
+
+			callStack = callStack[:len(callStack)-1]
+			if len(callStack) > 0 {
+				parent := callStack[len(callStack)-1].node
+				if lowLink[node] < lowLink[parent] {
+					lowLink[parent] = lowLink[node]
+				}
+			}
+		}
+	}
+
+	// components are found in reverse topological order
+	for i, j := 0, len(components)-1; i < j; i, j = i+1, j-1 {
+		components[i], components[j] = components[j], components[i]
+	}
+	return components
+}
@@ -0,0 +1,126 @@
+package equations
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/dimchansky/ebsl-go/opinion"
+	"github.com/dimchansky/ebsl-go/trust"
+)
+
+// ConcurrentContext is implemented by contexts which are safe for concurrent use
+type ConcurrentContext interface {
+	// SafeForConcurrentUse is a marker method, it does nothing
+	SafeForConcurrentUse()
+}
+
+// ConcurrentFinalReferralTrustEquationContext is a final referral trust equation context that is safe for concurrent use.
+// Final referral trust is stored by rows (source nodes), so equations of different sources can be evaluated in parallel
+// with little contention.
+type ConcurrentFinalReferralTrustEquationContext struct {
+	DirectReferralTrust trust.DirectReferralOpinion
+	discount            DiscountFun
+	discountGradient    DiscountGradientFun
+	initialization      Initialization
+
+	mu   sync.RWMutex
+	rows map[uint64]*concurrentRow
+}
+
+type concurrentRow struct {
+	mu     sync.RWMutex
+	values map[uint64]opinion.Type
+}
+
+// NewConcurrentFinalReferralTrustEquationContext creates final referral trust equation context that is safe for concurrent use.
+// Direct referral trust must not be modified while the context is used.
+func NewConcurrentFinalReferralTrustEquationContext(a trust.DirectReferralOpinion, opts ...ContextOption) *ConcurrentFinalReferralTrustEquationContext {
+	ctxOpts := newContextOptions(opts)
+	return &ConcurrentFinalReferralTrustEquationContext{
+		DirectReferralTrust: a,
+		discount:            ctxOpts.discount,
+		discountGradient:    ctxOpts.discountGradient,
+		initialization:      ctxOpts.initialization,
+		rows:                make(map[uint64]*concurrentRow),
+	}
+}
+
+// SafeForConcurrentUse implements ConcurrentContext interface
+func (c *ConcurrentFinalReferralTrustEquationContext) SafeForConcurrentUse() {}
+
+func (c *ConcurrentFinalReferralTrustEquationContext) GetDirectReferralTrust(link trust.Link) opinion.Type {
+	res, ok := c.DirectReferralTrust[link]
+	if !ok {
+		panic(fmt.Sprintf("direct referral trust not found: [%v, %v]", link.From, link.To))
+	}
+	return res
+}
+
+func (c *ConcurrentFinalReferralTrustEquationContext) GetFinalReferralTrust(link trust.Link) opinion.Type {
+	if row := c.getRow(link.From); row != nil {
+		row.mu.RLock()
+		res, ok := row.values[link.To]
+		row.mu.RUnlock()
+		if ok {
+			return res
+		}
+	}
+	return initialFinalReferralTrust(c.initialization, link)
+}
+
+func (c *ConcurrentFinalReferralTrustEquationContext) GetDiscount(o opinion.Type) float64 {
+	return discountOf(c.discount, o)
+}
+
+// GetDiscountGradient implements DiscountGradientContext interface
+func (c *ConcurrentFinalReferralTrustEquationContext) GetDiscountGradient(o opinion.Type) (dB, dD float64, ok bool) {
+	return discountGradientOf(c.discount, c.discountGradient, o)
+}
+
+func (c *ConcurrentFinalReferralTrustEquationContext) SetFinalReferralTrust(link trust.Link, value *opinion.Type) {
+	row := c.getRow(link.From)
+	if row == nil {
+		c.mu.Lock()
+		if row = c.rows[link.From]; row == nil {
+			row = &concurrentRow{values: make(map[uint64]opinion.Type)}
+			c.rows[link.From] = row
+		}
+		c.mu.Unlock()
+	}
+
+	row.mu.Lock()
+	row.values[link.To] = *value
+	row.mu.Unlock()
+}
+
+// RemoveFinalReferralTrust implements FinalReferralTrustRemover interface
+func (c *ConcurrentFinalReferralTrustEquationContext) RemoveFinalReferralTrust(link trust.Link) {
+	if row := c.getRow(link.From); row != nil {
+		row.mu.Lock()
+		delete(row.values, link.To)
+		row.mu.Unlock()
+	}
+}
+
+// FinalReferralTrust returns a copy of the final referral trust matrix
+func (c *ConcurrentFinalReferralTrustEquationContext) FinalReferralTrust() trust.FinalReferralOpinion {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	res := make(trust.FinalReferralOpinion)
+	for from, row := range c.rows {
+		row.mu.RLock()
+		for to, value := range row.values {
+			res[trust.Link{From: from, To: to}] = value
+		}
+		row.mu.RUnlock()
+	}
+	return res
+}
+
+func (c *ConcurrentFinalReferralTrustEquationContext) getRow(from uint64) *concurrentRow {
+	c.mu.RLock()
+	row := c.rows[from]
+	c.mu.RUnlock()
+	return row
+}
@@ -0,0 +1,125 @@
+package equations
+
+import (
+	"math"
+
+	"github.com/dimchansky/ebsl-go/opinion"
+)
+
+// DiscountFun maps opinion to the discount scalar in [0, 1] ("g" function of the paper).
+// Function must be monotone and continuous.
+type DiscountFun func(o opinion.Type) float64
+
+// DiscountGradientFun returns derivatives of the discount by belief and disbelief of the opinion,
+// uncertainty changes to keep the sum of the components equal to one
+type DiscountGradientFun func(o opinion.Type) (dB, dD float64)
+
+// minGradientBelief is the smallest belief used to differentiate discount functions with infinite derivative at zero belief
+const minGradientBelief = 1e-7
+
+// BeliefDiscount uses belief component of the opinion as discount
+func BeliefDiscount(o opinion.Type) float64 { return o.B }
+
+// BeliefDiscountGradient is the gradient of BeliefDiscount
+func BeliefDiscountGradient(opinion.Type) (dB, dD float64) { return 1, 0 }
+
+// SqrtBeliefDiscount uses square root of the belief component of the opinion as discount
+func SqrtBeliefDiscount(o opinion.Type) float64 { return math.Sqrt(o.B) }
+
+// SqrtBeliefDiscountGradient is the gradient of SqrtBeliefDiscount, derivative is infinite at zero belief,
+// so it is bounded by the derivative at small positive belief
+func SqrtBeliefDiscountGradient(o opinion.Type) (dB, dD float64) {
+	return 1 / (2 * math.Sqrt(math.Max(o.B, minGradientBelief))), 0
+}
+
+// ProjectedProbabilityDiscount returns discount function b + a·u, where `a` is the base rate in [0, 1]
+func ProjectedProbabilityDiscount(baseRate float64) DiscountFun {
+	return func(o opinion.Type) float64 {
+		return o.B + baseRate*o.U
+	}
+}
+
+// ProjectedProbabilityDiscountGradient returns the gradient of ProjectedProbabilityDiscount
+func ProjectedProbabilityDiscountGradient(baseRate float64) DiscountGradientFun {
+	return func(opinion.Type) (dB, dD float64) {
+		return 1 - baseRate, -baseRate
+	}
+}
+
+// ThresholdedBeliefDiscount returns discount function that is zero for belief below the threshold and grows linearly
+// from zero to one when belief grows from the threshold to one. Threshold must be in [0, 1).
+func ThresholdedBeliefDiscount(threshold float64) DiscountFun {
+	return func(o opinion.Type) float64 {
+		if o.B <= threshold {
+			return 0
+		}
+		return (o.B - threshold) / (1 - threshold)
+	}
+}
+
+// ThresholdedBeliefDiscountGradient returns the gradient of ThresholdedBeliefDiscount
+// (right derivative is used at the threshold)
+func ThresholdedBeliefDiscountGradient(threshold float64) DiscountGradientFun {
+	return func(o opinion.Type) (dB, dD float64) {
+		if o.B < threshold {
+			return 0, 0
+		}
+		return 1 / (1 - threshold), 0
+	}
+}
+
+// EvidenceSaturatingDiscount returns discount function p/(p+k), where p is amount of positive evidence of the opinion
+// (using `c` as soft threshold/"unit" of evidence) and k is the amount of positive evidence that gives discount 1/2.
+func EvidenceSaturatingDiscount(c uint64, k float64) DiscountFun {
+	return func(o opinion.Type) float64 {
+		// p/(p+k) where p = c·b/u
+		cb := float64(c) * o.B
+		denominator := cb + k*o.U
+		if denominator == 0 {
+			return 0
+		}
+		return cb / denominator
+	}
+}
+
+// EvidenceSaturatingDiscountGradient returns the gradient of EvidenceSaturatingDiscount
+func EvidenceSaturatingDiscountGradient(c uint64, k float64) DiscountGradientFun {
+	return func(o opinion.Type) (dB, dD float64) {
+		cb := float64(c) * o.B
+		denominator := cb + k*o.U
+		if denominator == 0 {
+			return 0, 0
+		}
+		ck := float64(c) * k / (denominator * denominator)
+		return ck * (o.B + o.U), ck * o.B
+	}
+}
+
+// DiscountGradientContext is implemented by contexts which know the gradient of their discount function
+type DiscountGradientContext interface {
+	// GetDiscountGradient returns derivatives of the discount by belief and disbelief of the opinion
+	// (see DiscountGradientFun), ok is false if the gradient is unknown
+	GetDiscountGradient(o opinion.Type) (dB, dD float64, ok bool)
+}
+
+// discountOf returns discount of the opinion, BeliefDiscount is used if discount function is not set
+// (context is created as a struct literal)
+func discountOf(discount DiscountFun, o opinion.Type) float64 {
+	if discount == nil {
+		return BeliefDiscount(o)
+	}
+	return discount(o)
+}
+
+// discountGradientOf returns gradient of the discount of the opinion, BeliefDiscountGradient is used
+// if discount function is not set (context is created as a struct literal)
+func discountGradientOf(discount DiscountFun, gradient DiscountGradientFun, o opinion.Type) (dB, dD float64, ok bool) {
+	if discount == nil {
+		gradient = BeliefDiscountGradient
+	}
+	if gradient == nil {
+		return 0, 0, false
+	}
+	dB, dD = gradient(o)
+	return dB, dD, true
+}
@@ -0,0 +1,127 @@
+package equations_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/dimchansky/ebsl-go/opinion"
+	"github.com/dimchansky/ebsl-go/trust"
+	"github.com/dimchansky/ebsl-go/trust/equations"
+	"github.com/go-test/deep"
+)
+
+func TestDiscountFunctions(t *testing.T) {
+	o := opinion.New(0.64, 0.2, 0.16)
+
+	tests := []struct {
+		name     string
+		discount equations.DiscountFun
+		want     float64
+	}{
+		{"belief", equations.BeliefDiscount, 0.64},
+		{"sqrt belief", equations.SqrtBeliefDiscount, 0.8},
+		{"projected probability", equations.ProjectedProbabilityDiscount(0.5), 0.72},
+		{"thresholded belief below", equations.ThresholdedBeliefDiscount(0.8), 0},
+		{"thresholded belief above", equations.ThresholdedBeliefDiscount(0.6), 0.1},
+		{"evidence saturating", equations.EvidenceSaturatingDiscount(2, 8), 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := deep.Equal(tt.discount(o), tt.want); diff != nil {
+				t.Errorf("discount: %v", diff)
+			}
+		})
+	}
+}
+
+func TestDiscountGradients(t *testing.T) {
+	o := opinion.New(0.64, 0.2, 0.16)
+
+	tests := []struct {
+		name     string
+		discount equations.DiscountFun
+		gradient equations.DiscountGradientFun
+	}{
+		{"belief", equations.BeliefDiscount, equations.BeliefDiscountGradient},
+		{"sqrt belief", equations.SqrtBeliefDiscount, equations.SqrtBeliefDiscountGradient},
+		{"projected probability", equations.ProjectedProbabilityDiscount(0.3), equations.ProjectedProbabilityDiscountGradient(0.3)},
+		{"thresholded belief below", equations.ThresholdedBeliefDiscount(0.8), equations.ThresholdedBeliefDiscountGradient(0.8)},
+		{"thresholded belief above", equations.ThresholdedBeliefDiscount(0.6), equations.ThresholdedBeliefDiscountGradient(0.6)},
+		{"evidence saturating", equations.EvidenceSaturatingDiscount(2, 8), equations.EvidenceSaturatingDiscountGradient(2, 8)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const h = 1e-6
+			wantB := (tt.discount(opinion.New(o.B+h, o.D, o.U-h)) - tt.discount(opinion.New(o.B-h, o.D, o.U+h))) / (2 * h)
+			wantD := (tt.discount(opinion.New(o.B, o.D+h, o.U-h)) - tt.discount(opinion.New(o.B, o.D-h, o.U+h))) / (2 * h)
+
+			dB, dD := tt.gradient(o)
+			if math.Abs(dB-wantB) > 1e-6 || math.Abs(dD-wantD) > 1e-6 {
+				t.Errorf("gradient: got (%v, %v), want (%v, %v)", dB, dD, wantB, wantD)
+			}
+		})
+	}
+}
+
+func TestContextDiscountGradient(t *testing.T) {
+	o := opinion.New(0.64, 0.2, 0.16)
+	dro := trust.DirectReferralOpinion{}
+
+	t.Run("default", func(t *testing.T) {
+		dB, dD, ok := equations.NewDefaultFinalReferralTrustEquationContext(dro).GetDiscountGradient(o)
+		if !ok || dB != 1 || dD != 0 {
+			t.Errorf("got (%v, %v, %v), want gradient of belief discount", dB, dD, ok)
+		}
+	})
+
+	t.Run("custom discount", func(t *testing.T) {
+		_, _, ok := equations.NewConcurrentFinalReferralTrustEquationContext(dro,
+			equations.UseDiscount(equations.SqrtBeliefDiscount)).GetDiscountGradient(o)
+		if ok {
+			t.Error("gradient of custom discount is known")
+		}
+	})
+
+	t.Run("custom discount with gradient", func(t *testing.T) {
+		dB, dD, ok := equations.NewDefaultFinalReferralTrustEquationContext(dro,
+			equations.UseDiscountGradient(equations.ProjectedProbabilityDiscountGradient(0.5)),
+			equations.UseDiscount(equations.ProjectedProbabilityDiscount(0.5))).GetDiscountGradient(o)
+		if !ok || dB != 0.5 || dD != -0.5 {
+			t.Errorf("got (%v, %v, %v), want (0.5, -0.5, true)", dB, dD, ok)
+		}
+	})
+}
+
+func TestEvidenceSaturatingDiscountOfDogmaticOpinion(t *testing.T) {
+	discount := equations.EvidenceSaturatingDiscount(2, 8)
+
+	if got := discount(opinion.FullBelief()); got != 1 {
+		t.Errorf("discount of full belief: got %v, want 1", got)
+	}
+	if got := discount(opinion.FullDisbelief()); got != 0 {
+		t.Errorf("discount of full disbelief: got %v, want 0", got)
+	}
+}
+
+func TestContextLiteralUsesDefaults(t *testing.T) {
+	o := opinion.New(0.64, 0.2, 0.16)
+	link := trust.Link{From: 1, To: 2}
+
+	contexts := []struct {
+		name    string
+		context equations.FinalReferralTrustExpressionContext
+	}{
+		{"default", &equations.DefaultFinalReferralTrustEquationContext{FinalReferralTrust: make(trust.FinalReferralOpinion)}},
+		{"concurrent", &equations.ConcurrentFinalReferralTrustEquationContext{}},
+	}
+	for _, tt := range contexts {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.context.GetDiscount(o); got != 0.64 {
+				t.Errorf("GetDiscount: got %v, want belief discount 0.64", got)
+			}
+			if diff := deep.Equal(tt.context.GetFinalReferralTrust(link), opinion.FullBelief()); diff != nil {
+				t.Errorf("GetFinalReferralTrust: %v", diff)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package equations
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/dimchansky/ebsl-go/opinion"
 	"github.com/dimchansky/ebsl-go/trust"
@@ -103,12 +104,68 @@ func EvaluateFinalFunctionalTrust(ctx FinalFunctionalTrustContext, of uint64, df
 type DefaultFinalReferralTrustEquationContext struct {
 	DirectReferralTrust trust.DirectReferralOpinion
 	FinalReferralTrust  trust.FinalReferralOpinion
+	discount            DiscountFun
+	discountGradient    DiscountGradientFun
+	initialization      Initialization
 }
 
-func NewDefaultFinalReferralTrustEquationContext(a trust.DirectReferralOpinion) *DefaultFinalReferralTrustEquationContext {
+// ContextOption configures final referral trust equation context
+type ContextOption func(opts *contextOptions)
+
+type contextOptions struct {
+	discount         DiscountFun
+	discountGradient DiscountGradientFun
+	initialization   Initialization
+}
+
+// UseDiscount sets discount function of the context (BeliefDiscount is used by default)
+func UseDiscount(discount DiscountFun) ContextOption {
+	return func(opts *contextOptions) {
+		opts.discount = discount
+	}
+}
+
+// UseDiscountGradient sets gradient of the discount function of the context, it is used by Newton solver
+// (BeliefDiscountGradient is used by default if discount function is not set, gradient of the custom discount
+// function is approximated by finite differences if it is not set)
+func UseDiscountGradient(gradient DiscountGradientFun) ContextOption {
+	return func(opts *contextOptions) {
+		opts.discountGradient = gradient
+	}
+}
+
+// UseInitialization sets initial value of the final referral trust that is not evaluated yet
+// (FullBeliefInitialization is used by default)
+func UseInitialization(initialization Initialization) ContextOption {
+	return func(opts *contextOptions) {
+		opts.initialization = initialization
+	}
+}
+
+func newContextOptions(opts []ContextOption) *contextOptions {
+	ctxOpts := &contextOptions{
+		initialization: FullBeliefInitialization,
+	}
+	for _, applyOption := range opts {
+		applyOption(ctxOpts)
+	}
+	if ctxOpts.discount == nil {
+		ctxOpts.discount = BeliefDiscount
+		if ctxOpts.discountGradient == nil {
+			ctxOpts.discountGradient = BeliefDiscountGradient
+		}
+	}
+	return ctxOpts
+}
+
+func NewDefaultFinalReferralTrustEquationContext(a trust.DirectReferralOpinion, opts ...ContextOption) *DefaultFinalReferralTrustEquationContext {
+	ctxOpts := newContextOptions(opts)
 	return &DefaultFinalReferralTrustEquationContext{
 		DirectReferralTrust: a,
 		FinalReferralTrust:  make(trust.FinalReferralOpinion),
+		discount:            ctxOpts.discount,
+		discountGradient:    ctxOpts.discountGradient,
+		initialization:      ctxOpts.initialization,
 	}
 }
 
@@ -124,86 +181,256 @@ func (c *DefaultFinalReferralTrustEquationContext) GetFinalReferralTrust(link tr
 	if res, ok := c.FinalReferralTrust[link]; ok {
 		return res
 	}
-	return opinion.FullBelief()
+	return initialFinalReferralTrust(c.initialization, link)
 }
 
 func (c *DefaultFinalReferralTrustEquationContext) GetDiscount(o opinion.Type) float64 {
-	return o.B
+	return discountOf(c.discount, o)
+}
+
+// GetDiscountGradient implements DiscountGradientContext interface
+func (c *DefaultFinalReferralTrustEquationContext) GetDiscountGradient(o opinion.Type) (dB, dD float64, ok bool) {
+	return discountGradientOf(c.discount, c.discountGradient, o)
 }
 
 func (c *DefaultFinalReferralTrustEquationContext) SetFinalReferralTrust(link trust.Link, value *opinion.Type) {
 	c.FinalReferralTrust[link] = *value
 }
 
+// RemoveFinalReferralTrust implements FinalReferralTrustRemover interface
+func (c *DefaultFinalReferralTrustEquationContext) RemoveFinalReferralTrust(link trust.Link) {
+	delete(c.FinalReferralTrust, link)
+}
+
+// initialFinalReferralTrust returns initial value of R[i, j], R[i, i] is always full belief.
+// FullBeliefInitialization is used if initialization is not set (context is created as a struct literal).
+func initialFinalReferralTrust(initialization Initialization, link trust.Link) opinion.Type {
+	if link.From == link.To || initialization == nil {
+		return opinion.FullBelief()
+	}
+	return initialization(link)
+}
+
+// EquationsOption configures creation of equations
+type EquationsOption func(opts *equationsOptions)
+
+type equationsOptions struct {
+	sorted  bool
+	sources []uint64 // nil if equations of all source nodes are created
+}
+
+// UseSortedOrder makes equations to be generated in a stable order: sorted by source and then by destination,
+// terms of the consensus are sorted too. Solving such equations gives reproducible results.
+func UseSortedOrder() EquationsOption {
+	return func(opts *equationsOptions) {
+		opts.sorted = true
+	}
+}
+
+// UseSources makes equations to be created only for the given source nodes (rows of final trust),
+// so work of creating and solving them grows with the size of the graph reachable from the sources only.
+// Equations of all source nodes are created by default.
+func UseSources(sources ...uint64) EquationsOption {
+	return func(opts *equationsOptions) {
+		if opts.sources == nil {
+			opts.sources = make([]uint64, 0, len(sources))
+		}
+		opts.sources = append(opts.sources, sources...)
+	}
+}
+
+func newEquationsOptions(opts []EquationsOption) *equationsOptions {
+	eqsOpts := &equationsOptions{}
+	for _, applyOption := range opts {
+		applyOption(eqsOpts)
+	}
+	if eqsOpts.sources != nil {
+		eqsOpts.sources = uniqueNodes(eqsOpts.sources, eqsOpts.sorted)
+	}
+	return eqsOpts
+}
+
+// uniqueNodes removes duplicates keeping the first occurrence of every node, nodes are sorted if `sorted` is true
+func uniqueNodes(nodes []uint64, sorted bool) []uint64 {
+	seen := make(uint64Set, len(nodes))
+	res := make([]uint64, 0, len(nodes))
+	for _, node := range nodes {
+		if !seen[node] {
+			seen[node] = true
+			res = append(res, node)
+		}
+	}
+	if sorted {
+		sortNodes(res)
+	}
+	return res
+}
+
 // CreateFinalReferralTrustEquations creates equations for the final referral trust
-func CreateFinalReferralTrustEquations(links trust.IterableLinks) IterableFinalReferralTrustEquations {
+func CreateFinalReferralTrustEquations(links trust.IterableLinks, opts ...EquationsOption) IterableFinalReferralTrustEquations {
+	return newIterableEquations(links, opts)
+}
+
+func newIterableEquations(links trust.IterableLinks, opts []EquationsOption) iterableEquations {
+	eqsOpts := newEquationsOptions(opts)
 	sourceGraph, sinkGraph := buildGraph(links)
 
-	return iterableEquations{sourceGraph: sourceGraph, sinkGraph: sinkGraph}
+	return iterableEquations{
+		sourceGraph: newNodeGraph(sourceGraph, eqsOpts.sorted),
+		sinkGraph:   newNodeGraph(sinkGraph, eqsOpts.sorted),
+		sources:     eqsOpts.sources,
+	}
 }
 
 type iterableEquations struct {
-	sourceGraph map[uint64]uint64Set // in source graph all keys are source vertexes and values are sink vertexes
-	sinkGraph   map[uint64]uint64Set // in sink graph all keys are sink vertexes and values are source vertexes
+	sourceGraph nodeGraph // in source graph all keys are source vertexes and values are sink vertexes
+	sinkGraph   nodeGraph // in sink graph all keys are sink vertexes and values are source vertexes
+	sources     []uint64  // source nodes to create equations for (nil for all source nodes)
 }
 
 func (ec iterableEquations) GetFinalReferralTrustEquationIterator() FinalReferralTrustEquationIterator {
-	sourceGraph := ec.sourceGraph
-	sinkGraph := ec.sinkGraph
-
 	return func(onNext NextFinalReferralTrustEquationHandler) error {
-
-		stack := make([]uint64, 0, len(sinkGraph)) // reusable stack of nodes to visit
-		for from := range sourceGraph {
-			// mark all isReachable nodes from current node
-			isReachable := map[uint64]bool{from: true}
-			stack = append(stack, from)
-			for len(stack) > 0 {
-				n := len(stack) - 1
-				sourceNode := stack[n]
-				stack = stack[:n]
-
-				sinkNodes := sourceGraph[sourceNode]
-				for sinkNode := range sinkNodes {
-					if !isReachable[sinkNode] {
-						isReachable[sinkNode] = true
-						stack = append(stack, sinkNode)
+		return ec.forEachSource(func(from uint64, reachable []uint64, isReachable map[uint64]bool) error {
+			// generate equations for final referral trust (R)
+			for _, to := range reachable {
+				if eq := ec.createEquation(from, to, isReachable); eq != nil {
+					if err := onNext(eq); err != nil {
+						return err
 					}
 				}
 			}
+			return nil
+		})
+	}
+}
 
-			// R[from,from] = full belief (skip it)
-			delete(isReachable, from)
+// forEachSource calls `onSource` for every source node with the list of nodes reachable from it
+// (`from` is not in the list) and set of reachable nodes (`from` is in the set).
+// List of reachable nodes is reused, so it must not be retained by `onSource`.
+func (ec iterableEquations) forEachSource(onSource func(from uint64, reachable []uint64, isReachable map[uint64]bool) error) error {
+	sourceGraph := ec.sourceGraph
+	sinkGraph := ec.sinkGraph
 
-			// generate equations for final referral trust (R)
-			for to := range isReachable {
-				var rExp expression = u{}
-				for k := range sinkGraph[to] {
-					if k == from { // diagonal in R equal to full belief
-						rExp = rExp.circlePlus(a{From: k, To: to})
-					} else if k != to && // diagonal in A equal to full uncertainty
-						isReachable[k] { // should exists path from "from" to "k"
-						rExp = rExp.circlePlus(discountingRule{r{From: from, To: k}, a{From: k, To: to}})
-					}
-				}
+	stack := make([]uint64, 0, sinkGraph.len())     // reusable stack of nodes to visit
+	reachable := make([]uint64, 0, sinkGraph.len()) // reusable list of reachable nodes
+	sources := ec.sources
+	if sources == nil {
+		sources = sourceGraph.nodes()
+	}
 
-				if !rExp.IsFullUncertainty() {
-					if err := onNext(&FinalReferralTrustEquation{
-						R:          trust.Link{From: from, To: to},
-						Expression: rExp,
-					}); err != nil {
-						return err
-					}
+	for _, from := range sources {
+		// mark all isReachable nodes from current node,
+		// R[from,from] = full belief, so `from` is never added to the list of reachable nodes
+		isReachable := map[uint64]bool{from: true}
+		reachable = reachable[:0]
+		stack = append(stack, from)
+		for len(stack) > 0 {
+			n := len(stack) - 1
+			sourceNode := stack[n]
+			stack = stack[:n]
+
+			sourceGraph.forEachAdjacent(sourceNode, func(sinkNode uint64) {
+				if !isReachable[sinkNode] {
+					isReachable[sinkNode] = true
+					reachable = append(reachable, sinkNode)
+					stack = append(stack, sinkNode)
 				}
-			}
+			})
+		}
+		sinkGraph.sort(reachable)
+
+		if err := onSource(from, reachable, isReachable); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createEquation creates equation of R[from, to] or returns nil if its expression is full uncertainty
+func (ec iterableEquations) createEquation(from, to uint64, isReachable map[uint64]bool) *FinalReferralTrustEquation {
+	var rExp expression = u{}
+	ec.sinkGraph.forEachAdjacent(to, func(k uint64) {
+		if k == from { // diagonal in R equal to full belief
+			rExp = rExp.circlePlus(a{From: k, To: to})
+		} else if k != to && // diagonal in A equal to full uncertainty
+			isReachable[k] { // should exists path from "from" to "k"
+			rExp = rExp.circlePlus(discountingRule{r{From: from, To: k}, a{From: k, To: to}})
 		}
+	})
 
+	if rExp.IsFullUncertainty() {
 		return nil
 	}
+	return &FinalReferralTrustEquation{
+		R:          trust.Link{From: from, To: to},
+		Expression: rExp,
+	}
+}
+
+// nodeGraph is adjacency sets of the graph that can be traversed either in map order or in sorted order
+type nodeGraph struct {
+	adjacent map[uint64]uint64Set
+	sorted   map[uint64][]uint64 // sorted adjacency lists (nil if graph is traversed in map order)
+}
+
+func newNodeGraph(adjacent map[uint64]uint64Set, sorted bool) nodeGraph {
+	g := nodeGraph{adjacent: adjacent}
+	if sorted {
+		g.sorted = make(map[uint64][]uint64, len(adjacent))
+		for node, set := range adjacent {
+			g.sorted[node] = set.sortedKeys()
+		}
+	}
+	return g
+}
+
+func (g nodeGraph) len() int { return len(g.adjacent) }
+
+// nodes returns all keys of the graph
+func (g nodeGraph) nodes() []uint64 {
+	res := make([]uint64, 0, len(g.adjacent))
+	for node := range g.adjacent {
+		res = append(res, node)
+	}
+	g.sort(res)
+	return res
+}
+
+// sort sorts nodes if graph is traversed in sorted order
+func (g nodeGraph) sort(nodes []uint64) {
+	if g.sorted != nil {
+		sortNodes(nodes)
+	}
+}
+
+func (g nodeGraph) forEachAdjacent(node uint64, f func(uint64)) {
+	if g.sorted != nil {
+		for _, adjacent := range g.sorted[node] {
+			f(adjacent)
+		}
+		return
+	}
+	for adjacent := range g.adjacent[node] {
+		f(adjacent)
+	}
 }
 
 type uint64Set map[uint64]bool
 
+func (s uint64Set) sortedKeys() []uint64 {
+	res := make([]uint64, 0, len(s))
+	for key := range s {
+		res = append(res, key)
+	}
+	sortNodes(res)
+	return res
+}
+
+func sortNodes(nodes []uint64) {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i] < nodes[j] })
+}
+
 // in source graph all keys are source vertexes and values are sink vertexes
 // in sink graph all keys are sink vertexes and values are source vertexes
 func buildGraph(links trust.IterableLinks) (sourceGraph map[uint64]uint64Set, sinkGraph map[uint64]uint64Set) {
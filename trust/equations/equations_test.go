@@ -129,6 +129,209 @@ func TestCreateFinalReferralTrustEquations(t *testing.T) {
 	}
 }
 
+func TestCreateFinalReferralTrustEquationsInSortedOrder(t *testing.T) {
+	var ls links
+	for i := uint64(1); i <= 6; i++ {
+		for j := uint64(1); j <= 6; j++ {
+			if i != j {
+				ls = append(ls, trust.Link{From: i, To: j})
+			}
+		}
+	}
+
+	var gotOrder []trust.Link
+	foreachEquation := equations.CreateFinalReferralTrustEquations(ls, equations.UseSortedOrder()).GetFinalReferralTrustEquationIterator()
+	_ = foreachEquation(func(eq *equations.FinalReferralTrustEquation) error {
+		gotOrder = append(gotOrder, eq.R)
+
+		terms := &termsCollector{}
+		if err := eq.Expression.Accept(terms); err != nil {
+			t.Fatal(err)
+		}
+		if !sort.SliceIsSorted(terms.links, func(i, j int) bool { return terms.links[i].Less(terms.links[j]) }) {
+			t.Errorf("terms of R[%v,%v] are not sorted: %v", eq.R.From, eq.R.To, terms.links)
+		}
+		return nil
+	})
+
+	if !sort.SliceIsSorted(gotOrder, func(i, j int) bool { return gotOrder[i].Less(gotOrder[j]) }) {
+		t.Errorf("equations are not sorted: %v", gotOrder)
+	}
+	if len(gotOrder) != len(ls) {
+		t.Errorf("got %v equations, want %v", len(gotOrder), len(ls))
+	}
+}
+
+func TestCreateFinalReferralTrustEquationsOfSources(t *testing.T) {
+	var ls links
+	for i := uint64(1); i <= 5; i++ {
+		ls = append(ls, trust.Link{From: i, To: i%5 + 1}, trust.Link{From: i, To: (i+1)%5 + 1})
+	}
+
+	all := toStringEquations(equations.CreateFinalReferralTrustEquations(ls, equations.UseSortedOrder()))
+	want := make(strEquations)
+	for link, eq := range all {
+		if link.From == 2 || link.From == 4 {
+			want[link] = eq
+		}
+	}
+
+	got := toStringEquations(equations.CreateFinalReferralTrustEquations(ls, equations.UseSortedOrder(), equations.UseSources(4, 2, 4, 42)))
+
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("CreateFinalReferralTrustEquations: %v", diff)
+	}
+}
+
+func TestCreateAffectedFinalReferralTrustEquations(t *testing.T) {
+	ls := links{{From: 1, To: 2}, {From: 2, To: 3}, {From: 3, To: 4}}
+
+	tests := []struct {
+		name        string
+		links       links
+		changed     links
+		wantEqs     []trust.Link
+		wantRemoved trust.Links
+	}{
+		{"updated", ls, links{{From: 3, To: 4}},
+			[]trust.Link{{From: 1, To: 4}, {From: 2, To: 4}, {From: 3, To: 4}},
+			nil,
+		},
+		{"added", append(links{{From: 4, To: 1}}, ls...), links{{From: 4, To: 1}},
+			[]trust.Link{
+				{From: 1, To: 2}, {From: 1, To: 3}, {From: 1, To: 4},
+				{From: 2, To: 1}, {From: 2, To: 3}, {From: 2, To: 4},
+				{From: 3, To: 1}, {From: 3, To: 2}, {From: 3, To: 4},
+				{From: 4, To: 1}, {From: 4, To: 2}, {From: 4, To: 3},
+			},
+			nil,
+		},
+		{"removed", links{{From: 1, To: 2}, {From: 3, To: 4}}, links{{From: 2, To: 3}},
+			nil,
+			trust.Links{{From: 1, To: 3}, {From: 1, To: 4}, {From: 2, To: 3}, {From: 2, To: 4}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eqs, removed := equations.CreateAffectedFinalReferralTrustEquations(tt.links, tt.changed, equations.UseSortedOrder())
+
+			var gotEqs []trust.Link
+			for _, eq := range eqs {
+				gotEqs = append(gotEqs, eq.R)
+			}
+
+			if diff := deep.Equal(gotEqs, tt.wantEqs); diff != nil {
+				t.Errorf("equations: %v", diff)
+			}
+			if diff := deep.Equal(removed, tt.wantRemoved); diff != nil {
+				t.Errorf("removed: %v", diff)
+			}
+		})
+	}
+}
+
+func TestCreateFinalReferralTrustEquationBlocks(t *testing.T) {
+	ls := links{
+		{From: 1, To: 2},
+		{From: 2, To: 3},
+		{From: 3, To: 2},
+		{From: 3, To: 4},
+		{From: 4, To: 5},
+		{From: 5, To: 4},
+		{From: 5, To: 6},
+	}
+
+	type block struct {
+		Links  []trust.Link
+		Cyclic bool
+	}
+	want := []block{
+		{[]trust.Link{{From: 1, To: 2}, {From: 1, To: 3}}, true},
+		{[]trust.Link{{From: 1, To: 4}, {From: 1, To: 5}}, true},
+		{[]trust.Link{{From: 1, To: 6}}, false},
+		{[]trust.Link{{From: 2, To: 3}}, false},
+		{[]trust.Link{{From: 2, To: 4}, {From: 2, To: 5}}, true},
+		{[]trust.Link{{From: 2, To: 6}}, false},
+		{[]trust.Link{{From: 3, To: 4}, {From: 3, To: 5}}, true},
+		{[]trust.Link{{From: 3, To: 6}}, false},
+		{[]trust.Link{{From: 3, To: 2}}, false},
+		{[]trust.Link{{From: 4, To: 5}}, false},
+		{[]trust.Link{{From: 4, To: 6}}, false},
+		{[]trust.Link{{From: 5, To: 6}}, false},
+		{[]trust.Link{{From: 5, To: 4}}, false},
+	}
+
+	var got []block
+	foreachBlock := equations.CreateFinalReferralTrustEquationBlocks(ls, equations.UseSortedOrder()).GetFinalReferralTrustEquationBlockIterator()
+	if err := foreachBlock(func(b *equations.FinalReferralTrustEquationBlock) error {
+		var blockLinks []trust.Link
+		for _, eq := range b.Equations {
+			blockLinks = append(blockLinks, eq.R)
+		}
+		got = append(got, block{Links: blockLinks, Cyclic: b.Cyclic})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("CreateFinalReferralTrustEquationBlocks: %v", diff)
+	}
+}
+
+func TestCreateFinalFunctionalTrustEquations(t *testing.T) {
+	tests := []struct {
+		name            string
+		referralLinks   links
+		functionalLinks links
+		want            strEquations
+	}{
+		{"1",
+			links{
+				trust.Link{From: 1, To: 2},
+				trust.Link{From: 2, To: 3},
+			},
+			links{
+				trust.Link{From: 1, To: 10},
+				trust.Link{From: 3, To: 10},
+				trust.Link{From: 2, To: 11},
+			},
+			strEquations{
+				trust.Link{From: 1, To: 10}: "(R[1,3] ⊠ A[3,10]) ⊕ A[1,10]",
+				trust.Link{From: 1, To: 11}: "(R[1,2] ⊠ A[2,11])",
+				trust.Link{From: 2, To: 10}: "(R[2,3] ⊠ A[3,10])",
+				trust.Link{From: 2, To: 11}: "A[2,11]",
+				trust.Link{From: 3, To: 10}: "A[3,10]",
+			},
+		},
+		{"2",
+			links{
+				trust.Link{From: 1, To: 2},
+				trust.Link{From: 2, To: 1},
+			},
+			links{
+				trust.Link{From: 1, To: 1},
+				trust.Link{From: 2, To: 1},
+				trust.Link{From: 3, To: 1},
+			},
+			strEquations{
+				trust.Link{From: 1, To: 1}: "(R[1,2] ⊠ A[2,1]) ⊕ A[1,1]",
+				trust.Link{From: 2, To: 1}: "(R[2,1] ⊠ A[1,1]) ⊕ A[2,1]",
+				trust.Link{From: 3, To: 1}: "A[3,1]",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toStringFunctionalEquations(equations.CreateFinalFunctionalTrustEquations(tt.referralLinks, tt.functionalLinks))
+
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Errorf("CreateFinalFunctionalTrustEquations: %v", diff)
+			}
+		})
+	}
+}
+
 var eqs equations.IterableFinalReferralTrustEquations
 
 func BenchmarkCreateFinalReferralTrustEquations(b *testing.B) {
@@ -184,6 +387,18 @@ func toStringEquations(eqs equations.IterableFinalReferralTrustEquations) strEqu
 	return r
 }
 
+func toStringFunctionalEquations(eqs equations.IterableFinalFunctionalTrustEquations) strEquations {
+	r := make(strEquations)
+
+	foreachEquation := eqs.GetFinalFunctionalTrustEquationIterator()
+	_ = foreachEquation(func(eq *equations.FinalFunctionalTrustEquation) error {
+		r[eq.F] = expressionToString(eq.Expression)
+		return nil
+	})
+
+	return r
+}
+
 func expressionToString(expr equations.FinalReferralTrustExpression) string {
 	s := &expressionStringer{}
 	if err := expr.Accept(s); err != nil {
@@ -231,6 +446,26 @@ func (s *expressionStringer) VisitConsensusListEnd() (err error) {
 	return
 }
 
+// termsCollector collects direct trust links of the expression terms in order of visiting
+type termsCollector struct {
+	links []trust.Link
+}
+
+func (c *termsCollector) VisitFullUncertainty() error { return nil }
+func (c *termsCollector) VisitDiscountingRule(r trust.Link, a trust.Link) error {
+	c.links = append(c.links, a)
+	return nil
+}
+func (c *termsCollector) VisitDirectReferralTrust(a trust.Link) error {
+	c.links = append(c.links, a)
+	return nil
+}
+func (c *termsCollector) VisitConsensusListStart(count int) error { return nil }
+func (c *termsCollector) VisitConsensusList(index int, equation equations.FinalReferralTrustExpression) error {
+	return equation.Accept(c)
+}
+func (c *termsCollector) VisitConsensusListEnd() error { return nil }
+
 func rToString(r trust.Link) string { return fmt.Sprintf("R[%v,%v]", r.From, r.To) }
 
 func aToString(a trust.Link) string { return fmt.Sprintf("A[%v,%v]", a.From, a.To) }
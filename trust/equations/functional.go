@@ -0,0 +1,201 @@
+package equations
+
+import (
+	"fmt"
+
+	"github.com/dimchansky/ebsl-go/opinion"
+	"github.com/dimchansky/ebsl-go/trust"
+)
+
+// FinalFunctionalTrustEquation represents final functional trust equation: F[i, p] = FinalReferralTrustExpression.
+// Direct trust links of the expression refer to the direct functional trust (opinions of entities about propositions).
+type FinalFunctionalTrustEquation struct {
+	// F represents F[i, p] - i's (possibly indirect) opinion about proposition p
+	F trust.Link
+	// FinalReferralTrustExpression of F[i, p]
+	Expression FinalReferralTrustExpression
+}
+
+// FinalFunctionalTrustEquationContext to evaluate final functional trust equation
+type FinalFunctionalTrustEquationContext interface {
+	FinalFunctionalTrustContext
+	GetDirectFunctionalTrust(link trust.Link) opinion.Type
+	// SetFinalFunctionalTrust used to update evaluated expression value
+	SetFinalFunctionalTrust(link trust.Link, value *opinion.Type)
+}
+
+// NextFinalFunctionalTrustEquationHandler handles next final functional trust equation and returns error
+type NextFinalFunctionalTrustEquationHandler func(*FinalFunctionalTrustEquation) error
+
+// FinalFunctionalTrustEquationIterator used as `foreach` to handle all final functional trust equations
+type FinalFunctionalTrustEquationIterator func(NextFinalFunctionalTrustEquationHandler) error
+
+// IterableFinalFunctionalTrustEquations allows to iterate over all final functional trust equations
+type IterableFinalFunctionalTrustEquations interface {
+	GetFinalFunctionalTrustEquationIterator() FinalFunctionalTrustEquationIterator
+}
+
+// FinalFunctionalTrustEquations is a set of final functional trust equation
+type FinalFunctionalTrustEquations []*FinalFunctionalTrustEquation
+
+// GetFinalFunctionalTrustEquationIterator implements IterableFinalFunctionalTrustEquations interface
+func (eqs FinalFunctionalTrustEquations) GetFinalFunctionalTrustEquationIterator() FinalFunctionalTrustEquationIterator {
+	return func(onNext NextFinalFunctionalTrustEquationHandler) error {
+		for _, equation := range eqs {
+			if err := onNext(equation); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// EvaluateFinalFunctionalTrust evaluates new final functional value from equation expression and updates final functional trust with the new value.
+func (e *FinalFunctionalTrustEquation) EvaluateFinalFunctionalTrust(context FinalFunctionalTrustEquationContext) (res *opinion.Type, err error) {
+	res, err = EvaluateFinalReferralTrustExpression(functionalExpressionContext{context}, e.Expression)
+	if err == nil {
+		context.SetFinalFunctionalTrust(e.F, res)
+	}
+	return res, err
+}
+
+// functionalExpressionContext evaluates direct trust of the expression as direct functional trust
+type functionalExpressionContext struct {
+	FinalFunctionalTrustEquationContext
+}
+
+func (c functionalExpressionContext) GetDirectReferralTrust(link trust.Link) opinion.Type {
+	return c.GetDirectFunctionalTrust(link)
+}
+
+// DefaultFinalFunctionalTrustEquationContext evaluates final functional trust using (already solved) final referral trust
+type DefaultFinalFunctionalTrustEquationContext struct {
+	FinalFunctionalTrustContext
+	DirectFunctionalTrust trust.DirectFunctionalOpinion
+	FinalFunctionalTrust  trust.FinalFunctionalOpinion
+}
+
+// NewDefaultFinalFunctionalTrustEquationContext creates final functional trust equation context,
+// final referral trust and discount are taken from the `referral` context.
+func NewDefaultFinalFunctionalTrustEquationContext(referral FinalFunctionalTrustContext, b trust.DirectFunctionalOpinion) *DefaultFinalFunctionalTrustEquationContext {
+	return &DefaultFinalFunctionalTrustEquationContext{
+		FinalFunctionalTrustContext: referral,
+		DirectFunctionalTrust:       b,
+		FinalFunctionalTrust:        make(trust.FinalFunctionalOpinion),
+	}
+}
+
+func (c *DefaultFinalFunctionalTrustEquationContext) GetDirectFunctionalTrust(link trust.Link) opinion.Type {
+	res, ok := c.DirectFunctionalTrust[link]
+	if !ok {
+		panic(fmt.Sprintf("direct functional trust not found: [%v, %v]", link.From, link.To))
+	}
+	return res
+}
+
+func (c *DefaultFinalFunctionalTrustEquationContext) SetFinalFunctionalTrust(link trust.Link, value *opinion.Type) {
+	c.FinalFunctionalTrust[link] = *value
+}
+
+// CreateFinalFunctionalTrustEquations creates equations for the final functional trust.
+// `referralLinks` are links of direct referral trust between entities, `functionalLinks` are links from entities to propositions.
+func CreateFinalFunctionalTrustEquations(referralLinks trust.IterableLinks, functionalLinks trust.IterableLinks, opts ...EquationsOption) IterableFinalFunctionalTrustEquations {
+	eqsOpts := newEquationsOptions(opts)
+	sourceGraph, _ := buildGraph(referralLinks)
+	propsGraph, believersGraph := buildGraph(functionalLinks)
+
+	return iterableFunctionalEquations{
+		sourceGraph:    newNodeGraph(sourceGraph, eqsOpts.sorted),
+		propsGraph:     newNodeGraph(propsGraph, eqsOpts.sorted),
+		believersGraph: newNodeGraph(believersGraph, eqsOpts.sorted),
+		sources:        eqsOpts.sources,
+	}
+}
+
+type iterableFunctionalEquations struct {
+	sourceGraph    nodeGraph // referral graph: keys are source entities and values are sink entities
+	propsGraph     nodeGraph // keys are entities and values are propositions they have opinion about
+	believersGraph nodeGraph // keys are propositions and values are entities having opinion about them
+	sources        []uint64  // entities to create equations for (nil for all entities)
+}
+
+func (ec iterableFunctionalEquations) GetFinalFunctionalTrustEquationIterator() FinalFunctionalTrustEquationIterator {
+	sourceGraph := ec.sourceGraph
+	propsGraph := ec.propsGraph
+	believersGraph := ec.believersGraph
+
+	entities := ec.sources
+	if entities == nil {
+		// every entity that either trusts someone or has an opinion about some proposition
+		entitiesSet := make(uint64Set, sourceGraph.len()+propsGraph.len())
+		for from := range sourceGraph.adjacent {
+			entitiesSet[from] = true
+		}
+		for from := range propsGraph.adjacent {
+			entitiesSet[from] = true
+		}
+		entities = make([]uint64, 0, len(entitiesSet))
+		for from := range entitiesSet {
+			entities = append(entities, from)
+		}
+		sourceGraph.sort(entities)
+	}
+
+	return func(onNext NextFinalFunctionalTrustEquationHandler) error {
+
+		stack := make([]uint64, 0, len(entities)) // reusable stack of nodes to visit
+		for _, from := range entities {
+			// mark all isReachable nodes from current node (including itself)
+			isReachable := map[uint64]bool{from: true}
+			stack = append(stack, from)
+			for len(stack) > 0 {
+				n := len(stack) - 1
+				sourceNode := stack[n]
+				stack = stack[:n]
+
+				sourceGraph.forEachAdjacent(sourceNode, func(sinkNode uint64) {
+					if !isReachable[sinkNode] {
+						isReachable[sinkNode] = true
+						stack = append(stack, sinkNode)
+					}
+				})
+			}
+
+			// propositions that reachable entities have opinion about
+			propsSet := make(uint64Set)
+			for k := range isReachable {
+				for p := range propsGraph.adjacent[k] {
+					propsSet[p] = true
+				}
+			}
+			props := make([]uint64, 0, len(propsSet))
+			for p := range propsSet {
+				props = append(props, p)
+			}
+			believersGraph.sort(props)
+
+			// generate equations for final functional trust (F)
+			for _, p := range props {
+				var fExp expression = u{}
+				believersGraph.forEachAdjacent(p, func(k uint64) {
+					if k == from { // diagonal in R equal to full belief
+						fExp = fExp.circlePlus(a{From: k, To: p})
+					} else if isReachable[k] { // should exists path from "from" to "k"
+						fExp = fExp.circlePlus(discountingRule{r{From: from, To: k}, a{From: k, To: p}})
+					}
+				})
+
+				if !fExp.IsFullUncertainty() {
+					if err := onNext(&FinalFunctionalTrustEquation{
+						F:          trust.Link{From: from, To: p},
+						Expression: fExp,
+					}); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		return nil
+	}
+}
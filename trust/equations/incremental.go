@@ -0,0 +1,130 @@
+package equations
+
+import (
+	"github.com/dimchansky/ebsl-go/trust"
+)
+
+// FinalReferralTrustRemover is implemented by contexts which can remove final referral trust value
+type FinalReferralTrustRemover interface {
+	RemoveFinalReferralTrust(link trust.Link)
+}
+
+// CreateAffectedFinalReferralTrustEquations creates equations of the final referral trust affected by the change of direct referral trust.
+// `links` are links of the direct referral trust after the change, `changed` are added, updated and removed links.
+// R[i, j] is affected if there is a changed link (k, l), such that k is i or reachable from i and j is l or reachable from l
+// (either before or after the change). Affected links of final referral trust that are not reachable after the change
+// are returned as removed.
+func CreateAffectedFinalReferralTrustEquations(
+	links trust.IterableLinks,
+	changed trust.IterableLinks,
+	opts ...EquationsOption,
+) (eqs FinalReferralTrustEquations, removed trust.Links) {
+	ec := newIterableEquations(links, opts)
+
+	// graph of links before and after the change
+	unionSourceGraph, unionSinkGraph := buildGraph(unionLinks{links, changed})
+	unionGraph := newNodeGraph(unionSourceGraph, ec.sourceGraph.sorted != nil)
+
+	changedGraph, _ := buildGraph(changed)
+
+	// sources that reach source of some changed link
+	sourcesSet := make(uint64Set)
+	for k := range changedGraph {
+		sourcesSet[k] = true
+		forEachReachable(newNodeGraph(unionSinkGraph, false), k, func(i uint64) { sourcesSet[i] = true })
+	}
+	if ec.sources != nil { // only equations of the given sources are created
+		allowed := make(uint64Set, len(ec.sources))
+		for _, i := range ec.sources {
+			allowed[i] = true
+		}
+		for i := range sourcesSet {
+			if !allowed[i] {
+				delete(sourcesSet, i)
+			}
+		}
+	}
+	sources := make([]uint64, 0, len(sourcesSet))
+	for i := range sourcesSet {
+		sources = append(sources, i)
+	}
+	ec.sourceGraph.sort(sources)
+	ec.sources = sources
+
+	_ = ec.forEachSource(func(from uint64, reachable []uint64, isReachable map[uint64]bool) error {
+		// targets of changed links whose source is `from` or is reachable from it
+		affected := make(uint64Set)
+		var targets []uint64
+		addTargets := func(k uint64) {
+			for l := range changedGraph[k] {
+				if !affected[l] {
+					affected[l] = true
+					targets = append(targets, l)
+				}
+			}
+		}
+		addTargets(from)
+		forEachReachable(unionGraph, from, addTargets)
+
+		// everything reachable from these targets is affected too
+		for _, l := range targets {
+			forEachReachable(unionGraph, l, func(j uint64) { affected[j] = true })
+		}
+		delete(affected, from)
+
+		for _, to := range reachable {
+			if affected[to] {
+				if eq := ec.createEquation(from, to, isReachable); eq != nil {
+					eqs = append(eqs, eq)
+				}
+			}
+		}
+
+		var removedSinks []uint64
+		for to := range affected {
+			if !isReachable[to] {
+				removedSinks = append(removedSinks, to)
+			}
+		}
+		ec.sinkGraph.sort(removedSinks)
+		for _, to := range removedSinks {
+			removed = append(removed, trust.Link{From: from, To: to})
+		}
+		return nil
+	})
+
+	return eqs, removed
+}
+
+// forEachReachable calls `f` for every node reachable from `from` (`from` itself is not passed unless it is on a cycle)
+func forEachReachable(g nodeGraph, from uint64, f func(uint64)) {
+	visited := make(uint64Set)
+	stack := []uint64{from}
+	for len(stack) > 0 {
+		n := len(stack) - 1
+		node := stack[n]
+		stack = stack[:n]
+
+		g.forEachAdjacent(node, func(adjacent uint64) {
+			if !visited[adjacent] {
+				visited[adjacent] = true
+				f(adjacent)
+				stack = append(stack, adjacent)
+			}
+		})
+	}
+}
+
+// unionLinks iterates over links of all iterables
+type unionLinks []trust.IterableLinks
+
+func (u unionLinks) GetLinkIterator() trust.LinkIterator {
+	return func(onNext trust.NextLinkHandler) error {
+		for _, links := range u {
+			if err := links.GetLinkIterator()(onNext); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,64 @@
+package equations
+
+import (
+	"github.com/dimchansky/ebsl-go/opinion"
+	"github.com/dimchansky/ebsl-go/trust"
+)
+
+// Initialization returns initial value of the final referral trust R[i, j] (i != j) that is not evaluated yet.
+// Solving equations from different initial values allows to check empirically that the fixed point is unique.
+type Initialization func(link trust.Link) opinion.Type
+
+// FullBeliefInitialization initializes final referral trust with full belief (default)
+func FullBeliefInitialization(trust.Link) opinion.Type { return opinion.FullBelief() }
+
+// FullUncertaintyInitialization initializes final referral trust with full uncertainty
+func FullUncertaintyInitialization(trust.Link) opinion.Type { return opinion.FullUncertainty() }
+
+// DirectTrustInitialization initializes final referral trust with direct referral trust where it exists
+// and with full uncertainty otherwise
+func DirectTrustInitialization(a trust.DirectReferralOpinion) Initialization {
+	return SolutionInitialization(trust.FinalReferralOpinion(a), FullUncertaintyInitialization)
+}
+
+// SolutionInitialization initializes final referral trust with previously saved solution,
+// links not found in the solution are initialized by `fallback`
+func SolutionInitialization(solution trust.FinalReferralOpinion, fallback Initialization) Initialization {
+	return func(link trust.Link) opinion.Type {
+		if res, ok := solution[link]; ok {
+			return res
+		}
+		return fallback(link)
+	}
+}
+
+// TopologicalEstimateInitialization initializes final referral trust with the estimate made by single evaluation
+// of every equation in topological order of the equation blocks (see CreateFinalReferralTrustEquationBlocks).
+// Equations outside of cycles get their exact values, equations of cycles use full uncertainty for the values
+// not evaluated yet. Links without equations are initialized with full uncertainty.
+// Context options are used to evaluate the estimate (initialization option is ignored).
+func TopologicalEstimateInitialization(a trust.DirectReferralOpinion, opts ...ContextOption) Initialization {
+	return TopologicalEstimateInitializationOfBlocks(a, CreateFinalReferralTrustEquationBlocks(a, UseSortedOrder()), opts...)
+}
+
+// TopologicalEstimateInitializationOfBlocks is TopologicalEstimateInitialization made by evaluation of the given blocks
+// (e.g. blocks of some source nodes only), blocks must be created from `a`.
+func TopologicalEstimateInitializationOfBlocks(
+	a trust.DirectReferralOpinion,
+	blocks IterableFinalReferralTrustEquationBlocks,
+	opts ...ContextOption,
+) Initialization {
+	context := NewDefaultFinalReferralTrustEquationContext(a, append(opts, UseInitialization(FullUncertaintyInitialization))...)
+
+	foreachBlock := blocks.GetFinalReferralTrustEquationBlockIterator()
+	_ = foreachBlock(func(block *FinalReferralTrustEquationBlock) error {
+		for _, eq := range block.Equations {
+			if _, err := eq.EvaluateFinalReferralTrust(context); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return SolutionInitialization(context.FinalReferralTrust, FullUncertaintyInitialization)
+}
@@ -0,0 +1,146 @@
+package equations
+
+import (
+	"fmt"
+
+	"github.com/dimchansky/ebsl-go/opinion"
+	"github.com/dimchansky/ebsl-go/trust"
+)
+
+// FinalMultinomialFunctionalTrustEquationContext to evaluate final functional trust equation
+// when direct functional trust is a multinomial opinion
+type FinalMultinomialFunctionalTrustEquationContext interface {
+	FinalFunctionalTrustContext
+	GetDirectMultinomialFunctionalTrust(link trust.Link) opinion.Multinomial
+	// SetFinalMultinomialFunctionalTrust used to update evaluated expression value
+	SetFinalMultinomialFunctionalTrust(link trust.Link, value *opinion.Multinomial)
+}
+
+// EvaluateFinalMultinomialFunctionalTrust evaluates new multinomial final functional value from equation expression
+// and updates final functional trust with the new value.
+func (e *FinalFunctionalTrustEquation) EvaluateFinalMultinomialFunctionalTrust(context FinalMultinomialFunctionalTrustEquationContext) (*opinion.Multinomial, error) {
+	ev := &multinomialExpressionEvaluator{context: context}
+	if err := e.Expression.Accept(ev); err != nil {
+		return nil, err
+	}
+	if ev.state != evaluated {
+		return nil, ErrInvalidExpression
+	}
+	context.SetFinalMultinomialFunctionalTrust(e.F, &ev.result)
+	return &ev.result, nil
+}
+
+// multinomialExpressionEvaluator evaluates expression of final functional trust equation,
+// direct trust of the expression is multinomial direct functional trust
+type multinomialExpressionEvaluator struct {
+	context FinalMultinomialFunctionalTrustEquationContext
+	result  opinion.Multinomial
+	state   evaluatorState
+}
+
+func (ev *multinomialExpressionEvaluator) VisitFullUncertainty() error {
+	if ev.state != notEvaluated {
+		return ErrInvalidExpression
+	}
+
+	ev.result = opinion.FullUncertaintyMultinomial(0)
+	ev.state = evaluated
+	return nil
+}
+
+func (ev *multinomialExpressionEvaluator) VisitDiscountingRule(r trust.Link, a trust.Link) (err error) {
+	switch ev.state {
+	case notEvaluated:
+		ctx := ev.context
+
+		alpha := ctx.GetDiscount(ctx.GetFinalReferralTrust(r))
+		aOp := ctx.GetDirectMultinomialFunctionalTrust(a)
+
+		ev.result = *aOp.Mul(alpha)
+		ev.state = evaluated
+	case consensus:
+		ctx := ev.context
+
+		alpha := ctx.GetDiscount(ctx.GetFinalReferralTrust(r))
+
+		aOp := ctx.GetDirectMultinomialFunctionalTrust(a)
+		ev.result.PlusMul(alpha, &aOp)
+	default:
+		err = ErrInvalidExpression
+	}
+	return
+}
+
+func (ev *multinomialExpressionEvaluator) VisitDirectReferralTrust(a trust.Link) (err error) {
+	switch ev.state {
+	case notEvaluated:
+		ev.result = ev.context.GetDirectMultinomialFunctionalTrust(a)
+		ev.state = evaluated
+	case consensus:
+		aOp := ev.context.GetDirectMultinomialFunctionalTrust(a)
+		ev.result.Plus(&aOp)
+	default:
+		err = ErrInvalidExpression
+	}
+	return
+}
+
+func (ev *multinomialExpressionEvaluator) VisitConsensusListStart(count int) error {
+	if ev.state != notEvaluated {
+		return ErrInvalidExpression
+	}
+
+	ev.state = consensus
+	ev.result = opinion.FullUncertaintyMultinomial(0)
+	return nil
+}
+
+func (ev *multinomialExpressionEvaluator) VisitConsensusList(index int, equation FinalReferralTrustExpression) error {
+	if ev.state != consensus {
+		return ErrInvalidExpression
+	}
+
+	return equation.Accept(ev)
+}
+
+func (ev *multinomialExpressionEvaluator) VisitConsensusListEnd() error {
+	if ev.state != consensus {
+		return ErrInvalidExpression
+	}
+
+	ev.state = evaluated
+	return nil
+}
+
+// DefaultFinalMultinomialFunctionalTrustEquationContext evaluates multinomial final functional trust
+// using (already solved) final referral trust
+type DefaultFinalMultinomialFunctionalTrustEquationContext struct {
+	FinalFunctionalTrustContext
+	DirectFunctionalTrust trust.DirectMultinomialFunctionalOpinion
+	FinalFunctionalTrust  trust.FinalMultinomialFunctionalOpinion
+}
+
+// NewDefaultFinalMultinomialFunctionalTrustEquationContext creates multinomial final functional trust equation context,
+// final referral trust and discount are taken from the `referral` context.
+func NewDefaultFinalMultinomialFunctionalTrustEquationContext(
+	referral FinalFunctionalTrustContext,
+	b trust.DirectMultinomialFunctionalOpinion,
+) *DefaultFinalMultinomialFunctionalTrustEquationContext {
+	return &DefaultFinalMultinomialFunctionalTrustEquationContext{
+		FinalFunctionalTrustContext: referral,
+		DirectFunctionalTrust:       b,
+		FinalFunctionalTrust:        make(trust.FinalMultinomialFunctionalOpinion),
+	}
+}
+
+func (c *DefaultFinalMultinomialFunctionalTrustEquationContext) GetDirectMultinomialFunctionalTrust(link trust.Link) opinion.Multinomial {
+	res, ok := c.DirectFunctionalTrust[link]
+	if !ok {
+		panic(fmt.Sprintf("direct functional trust not found: [%v, %v]", link.From, link.To))
+	}
+	return res
+}
+
+func (c *DefaultFinalMultinomialFunctionalTrustEquationContext) SetFinalMultinomialFunctionalTrust(link trust.Link, value *opinion.Multinomial) {
+	c.FinalFunctionalTrust[link] = *value
+}
@@ -0,0 +1,137 @@
+package solver
+
+import (
+	"github.com/dimchansky/ebsl-go/opinion"
+	"github.com/dimchansky/ebsl-go/trust/equations"
+)
+
+// anderson keeps history of Anderson mixing of every row.
+// Opinion of every equation is represented by belief and disbelief components, uncertainty is derived from them.
+type anderson struct {
+	depth int
+	prev  []opinion.Type // values of equations before evaluation in the last epoch
+	next  []opinion.Type // values of equations evaluated in the last epoch
+	rows  []andersonRow
+}
+
+// andersonRow keeps residuals (f = g(x) - x) and evaluated values (g(x)) of the recent epochs, oldest first
+type andersonRow struct {
+	fs [][]float64
+	gs [][]float64
+}
+
+func newAnderson(rows, count, depth int) *anderson {
+	return &anderson{
+		depth: depth,
+		prev:  make([]opinion.Type, count),
+		next:  make([]opinion.Type, count),
+		rows:  make([]andersonRow, rows),
+	}
+}
+
+// record saves value of the equation with index `idx` before and after evaluation
+func (a *anderson) record(idx int, prevValue, newValue *opinion.Type) {
+	a.prev[idx] = *prevValue
+	a.next[idx] = *newValue
+}
+
+// mix updates context with the values of equations of active rows mixed from the recent epochs
+func (s *system) mix(context equations.FinalReferralTrustEquationContext) {
+	a := s.anderson
+	for _, idx := range s.activeRows {
+		r := &s.rows[idx]
+		h := &a.rows[idx]
+
+		f, g := h.push(2*len(r.eqs), a.depth+1)
+		for i := range r.eqs {
+			x, gx := &a.prev[r.offset+i], &a.next[r.offset+i]
+			g[2*i], g[2*i+1] = gx.B, gx.D
+			f[2*i], f[2*i+1] = gx.B-x.B, gx.D-x.D
+		}
+
+		x, ok := h.extrapolate()
+		if !ok {
+			h.restart()
+			continue
+		}
+
+		for i, eq := range r.eqs {
+			value := opinion.Type{B: x[2*i], D: x[2*i+1], U: 1 - x[2*i] - x[2*i+1]}
+			context.SetFinalReferralTrust(eq.R, &value)
+		}
+	}
+}
+
+// push appends vectors of residual and evaluated values of the given size to the history,
+// the oldest vectors are reused when history reaches its capacity
+func (h *andersonRow) push(size, capacity int) (f, g []float64) {
+	if len(h.fs) < capacity {
+		f, g = make([]float64, size), make([]float64, size)
+	} else {
+		f, g = h.fs[0], h.gs[0]
+		copy(h.fs, h.fs[1:])
+		copy(h.gs, h.gs[1:])
+		h.fs, h.gs = h.fs[:len(h.fs)-1], h.gs[:len(h.gs)-1]
+	}
+	h.fs = append(h.fs, f)
+	h.gs = append(h.gs, g)
+	return
+}
+
+// restart leaves only the last epoch in the history
+func (h *andersonRow) restart() {
+	last := len(h.fs) - 1
+	h.fs[0], h.fs[last] = h.fs[last], h.fs[0]
+	h.gs[0], h.gs[last] = h.gs[last], h.gs[0]
+	h.fs, h.gs = h.fs[:1], h.gs[:1]
+}
+
+// extrapolate returns x = g[k] - Σ γ[j]·(g[j+1] - g[j]), where γ minimizes ‖f[k] - Σ γ[j]·(f[j+1] - f[j])‖.
+// It returns false if there is not enough history, least squares problem is degenerate or x is not a vector of valid opinions.
+func (h *andersonRow) extrapolate() (x []float64, ok bool) {
+	k := len(h.fs) - 1
+	if k == 0 {
+		return nil, false
+	}
+
+	// normal equations (ΔFᵀΔF)γ = ΔFᵀf[k] as augmented matrix
+	m := make([][]float64, k)
+	for p := 0; p < k; p++ {
+		m[p] = make([]float64, k+1)
+		for q := 0; q <= p; q++ {
+			v := dotDiff(h.fs[p+1], h.fs[p], h.fs[q+1], h.fs[q])
+			m[p][q], m[q][p] = v, v
+		}
+		for i, fk := range h.fs[k] {
+			m[p][k] += (h.fs[p+1][i] - h.fs[p][i]) * fk
+		}
+	}
+	gamma, ok := solveLinear(m)
+	if !ok {
+		return nil, false
+	}
+
+	x = make([]float64, len(h.gs[k]))
+	copy(x, h.gs[k])
+	for j, c := range gamma {
+		for i := range x {
+			x[i] -= c * (h.gs[j+1][i] - h.gs[j][i])
+		}
+	}
+
+	for i := 0; i < len(x); i += 2 {
+		b, d := x[i], x[i+1]
+		if !(b >= 0 && d >= 0 && b+d <= 1) { // also false for NaN
+			return nil, false
+		}
+	}
+	return x, true
+}
+
+// dotDiff returns dot product of (a1 - a0) and (b1 - b0)
+func dotDiff(a1, a0, b1, b0 []float64) (res float64) {
+	for i := range a1 {
+		res += (a1[i] - a0[i]) * (b1[i] - b0[i])
+	}
+	return
+}
@@ -0,0 +1,134 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dimchansky/ebsl-go/trust/equations"
+)
+
+// SolveFinalReferralTrustEquationBlocks solves blocks of final referral trust equations in topological order:
+// equation of acyclic block is evaluated exactly once, because all equations it depends on are already solved,
+// equations of cyclic block are solved iteratively (as SolveFinalReferralTrustEquations does).
+// Every cyclic block is solved with its own epochs, so epoch and active equations callbacks are not called.
+// Blocks are solved sequentially.
+// Result contains the largest number of epochs of the block and residuals aggregated over blocks for every epoch.
+func SolveFinalReferralTrustEquationBlocks(
+	frtContext equations.FinalReferralTrustEquationContext,
+	blocks equations.IterableFinalReferralTrustEquationBlocks,
+	opts ...Options,
+) (*Result, error) {
+	return SolveFinalReferralTrustEquationBlocksWithContext(context.Background(), frtContext, blocks, opts...)
+}
+
+// SolveFinalReferralTrustEquationBlocksWithContext solves blocks of final referral trust equations in topological order
+// until the context is done (see SolveFinalReferralTrustEquationBlocks and SolveFinalReferralTrustEquationsWithContext).
+func SolveFinalReferralTrustEquationBlocksWithContext(
+	ctx context.Context,
+	frtContext equations.FinalReferralTrustEquationContext,
+	blocks equations.IterableFinalReferralTrustEquationBlocks,
+	opts ...Options,
+) (*Result, error) {
+	startTime := time.Now()
+
+	solverOpts, err := newOptionsOfGivenEquations(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// options of cyclic blocks
+	blockOpts := *solverOpts
+	blockOpts.workers = 1
+	blockOpts.onEpochStart = func(epoch uint) error { return nil }
+	blockOpts.onEpochEnd = func(epoch uint, aggregatedDistance float64) error { return nil }
+	blockOpts.onActiveEquations = nil
+
+	res := &Result{Converged: true}
+	var residuals [][]float64 // residuals of every cyclic block
+	defer func() {
+		res.Residuals = aggregateResiduals(residuals, solverOpts.distanceAggregator)
+		if len(res.Residuals) == 0 && res.Epochs > 0 { // all blocks are acyclic
+			res.Residuals = []float64{0}
+		}
+		res.WallTime = time.Since(startTime)
+	}()
+
+	canceled := func() error {
+		res.Converged = false
+		return &CanceledError{Epoch: res.Epochs, Residual: res.Residual(), Err: ctx.Err()}
+	}
+
+	foreachBlock := blocks.GetFinalReferralTrustEquationBlockIterator()
+	err = foreachBlock(func(block *equations.FinalReferralTrustEquationBlock) error {
+		if isDone(ctx.Done()) {
+			return canceled()
+		}
+
+		if !block.Cyclic {
+			for _, eq := range block.Equations {
+				if err := evaluateDirectly(frtContext, solverOpts, eq); err != nil {
+					return err
+				}
+			}
+			res.DirectEquations += len(block.Equations)
+			if res.Epochs == 0 {
+				res.Epochs = 1
+			}
+			return nil
+		}
+
+		blockRes, err := solve(ctx, frtContext, block.Equations, &blockOpts, time.Now())
+		if blockRes != nil {
+			res.Converged = res.Converged && blockRes.Converged
+			if blockRes.Epochs > res.Epochs {
+				res.Epochs = blockRes.Epochs
+			}
+			residuals = append(residuals, blockRes.Residuals)
+			for _, ld := range blockRes.SlowestLinks {
+				res.SlowestLinks = insertLinkDistance(res.SlowestLinks, ld, solverOpts.slowestLinks)
+			}
+		}
+		if errors.As(err, new(*CanceledError)) {
+			return canceled()
+		}
+		if err != nil {
+			return err
+		}
+		res.IterativeEquations += len(block.Equations)
+		return nil
+	})
+	return res, err
+}
+
+// evaluateDirectly evaluates equation once and updates context with the new value
+func evaluateDirectly(context equations.FinalReferralTrustEquationContext, opts *options, eq *equations.FinalReferralTrustEquation) error {
+	prevValue := context.GetFinalReferralTrust(eq.R)
+	newValue, err := eq.EvaluateFinalReferralTrust(context)
+	if err != nil {
+		return err
+	}
+	if opts.onEquationEvaluated != nil {
+		return opts.onEquationEvaluated(eq.R, &prevValue, newValue, opts.distanceFun(&prevValue, newValue))
+	}
+	return nil
+}
+
+// aggregateResiduals aggregates residuals of the same epoch of all blocks
+func aggregateResiduals(residuals [][]float64, distanceAggregator DistanceAggregator) []float64 {
+	var res []float64
+	for epoch := 0; ; epoch++ {
+		distanceAggregator.Reset()
+		found := false
+		for _, blockResiduals := range residuals {
+			if epoch < len(blockResiduals) {
+				distanceAggregator.Add(blockResiduals[epoch])
+				found = true
+			}
+		}
+		if !found {
+			return res
+		}
+		res = append(res, distanceAggregator.Result())
+	}
+}
@@ -0,0 +1,32 @@
+package solver
+
+import (
+	"github.com/dimchansky/ebsl-go/trust/equations"
+)
+
+// SolveFinalFunctionalTrustEquations evaluates final functional trust equations.
+// Final functional trust depends only on final referral trust, so final referral trust equations must be solved first
+// and every equation is evaluated exactly once.
+func SolveFinalFunctionalTrustEquations(
+	context equations.FinalFunctionalTrustEquationContext,
+	eqs equations.IterableFinalFunctionalTrustEquations,
+) error {
+	foreachEquation := eqs.GetFinalFunctionalTrustEquationIterator()
+	return foreachEquation(func(eq *equations.FinalFunctionalTrustEquation) error {
+		_, err := eq.EvaluateFinalFunctionalTrust(context)
+		return err
+	})
+}
+
+// SolveFinalMultinomialFunctionalTrustEquations evaluates final functional trust equations of multinomial direct functional trust.
+// Final referral trust equations must be solved first.
+func SolveFinalMultinomialFunctionalTrustEquations(
+	context equations.FinalMultinomialFunctionalTrustEquationContext,
+	eqs equations.IterableFinalFunctionalTrustEquations,
+) error {
+	foreachEquation := eqs.GetFinalFunctionalTrustEquationIterator()
+	return foreachEquation(func(eq *equations.FinalFunctionalTrustEquation) error {
+		_, err := eq.EvaluateFinalMultinomialFunctionalTrust(context)
+		return err
+	})
+}
@@ -0,0 +1,41 @@
+package solver
+
+import (
+	"context"
+	"time"
+
+	"github.com/dimchansky/ebsl-go/trust"
+	"github.com/dimchansky/ebsl-go/trust/equations"
+)
+
+// SolveFinalReferralTrustIncrementally updates solved final referral trust after change of the direct referral trust.
+// `dro` must be the direct referral trust of the context: the change is applied to `dro` in place (see
+// trust.DirectReferralOpinionDelta.ApplyTo), so the context evaluates equations with the changed direct referral trust.
+// Only equations affected by the change are created (with options set by UseEquationsOptions) and solved,
+// solving starts from the final referral trust values of the context (warm start).
+// Final referral trust values that are not reachable after the change are removed from the context if it implements
+// equations.FinalReferralTrustRemover interface.
+func SolveFinalReferralTrustIncrementally(
+	ctx context.Context,
+	frtContext equations.FinalReferralTrustEquationContext,
+	dro trust.DirectReferralOpinion,
+	delta *trust.DirectReferralOpinionDelta,
+	opts ...Options,
+) (*Result, error) {
+	startTime := time.Now()
+
+	solverOpts, err := newOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	eqs, removed := equations.CreateAffectedFinalReferralTrustEquations(delta.ApplyTo(dro), delta, solverOpts.equationsOptions...)
+
+	if remover, ok := frtContext.(equations.FinalReferralTrustRemover); ok {
+		for _, link := range removed {
+			remover.RemoveFinalReferralTrust(link)
+		}
+	}
+
+	return solve(ctx, frtContext, eqs, solverOpts, startTime)
+}
@@ -0,0 +1,44 @@
+package solver
+
+import "math"
+
+// solveLinear solves linear system given as augmented n×(n+1) matrix using Gaussian elimination with partial pivoting,
+// matrix is modified. It returns false if the system is (nearly) singular.
+func solveLinear(m [][]float64) ([]float64, bool) {
+	n := len(m)
+	scale := 0.0
+	for i := range m {
+		scale = math.Max(scale, math.Abs(m[i][i]))
+	}
+	eps := scale * 1e-12
+
+	for c := 0; c < n; c++ {
+		pivot := c
+		for r := c + 1; r < n; r++ {
+			if math.Abs(m[r][c]) > math.Abs(m[pivot][c]) {
+				pivot = r
+			}
+		}
+		if !(math.Abs(m[pivot][c]) > eps) { // also true for NaN
+			return nil, false
+		}
+		m[c], m[pivot] = m[pivot], m[c]
+
+		for r := c + 1; r < n; r++ {
+			factor := m[r][c] / m[c][c]
+			for q := c; q <= n; q++ {
+				m[r][q] -= factor * m[c][q]
+			}
+		}
+	}
+
+	x := make([]float64, n)
+	for r := n - 1; r >= 0; r-- {
+		v := m[r][n]
+		for q := r + 1; q < n; q++ {
+			v -= m[r][q] * x[q]
+		}
+		x[r] = v / m[r][r]
+	}
+	return x, true
+}
@@ -0,0 +1,312 @@
+package solver
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/dimchansky/ebsl-go/opinion"
+	"github.com/dimchansky/ebsl-go/trust"
+	"github.com/dimchansky/ebsl-go/trust/equations"
+)
+
+// maxNewtonDampings is the maximum number of times Newton step is halved before falling back to fixed-point iteration
+const maxNewtonDampings = 10
+
+// discountDerivativeStep is the step of finite difference used to differentiate discount function without known gradient
+const discountDerivativeStep = 1e-7
+
+// SolveFinalReferralTrustEquationsNewton solves final referral trust equations using damped Newton method.
+// Equations of every source node are solved as a separate system: every epoch makes one Newton step for every row.
+// Step is halved until it decreases residual of the row, fixed-point iteration is used instead of the step if it
+// leaves the valid opinion simplex or does not decrease residual. Every step solves dense linear system, so cost of
+// the epoch grows cubically with the number of equations of the source node.
+// Options are the same as for SolveFinalReferralTrustEquations, except JacobiUpdate mode and Anderson acceleration:
+// ErrNewtonOptionsConflict is returned if they are used.
+func SolveFinalReferralTrustEquationsNewton(
+	frtContext equations.FinalReferralTrustEquationContext,
+	eqs equations.IterableFinalReferralTrustEquations,
+	opts ...Options,
+) (*Result, error) {
+	return SolveFinalReferralTrustEquationsNewtonWithContext(context.Background(), frtContext, eqs, opts...)
+}
+
+// SolveFinalReferralTrustEquationsNewtonWithContext solves final referral trust equations using damped Newton method
+// until the context is done (see SolveFinalReferralTrustEquationsNewton and SolveFinalReferralTrustEquationsWithContext).
+func SolveFinalReferralTrustEquationsNewtonWithContext(
+	ctx context.Context,
+	frtContext equations.FinalReferralTrustEquationContext,
+	eqs equations.IterableFinalReferralTrustEquations,
+	opts ...Options,
+) (*Result, error) {
+	startTime := time.Now()
+
+	solverOpts, err := newOptionsOfGivenEquations(opts)
+	if err != nil {
+		return nil, err
+	}
+	if solverOpts.updateMode != GaussSeidelUpdate || solverOpts.accelerationDepth > 0 {
+		return nil, ErrNewtonOptionsConflict
+	}
+	solverOpts.newton = true
+
+	return solve(ctx, frtContext, eqs, solverOpts, startTime)
+}
+
+// newtonTerm is a term of consensus: A[k, j] discounted by R[i, k] (discounting rule) or A[i, j] (direct referral trust)
+type newtonTerm struct {
+	r      trust.Link // R[i, k] of discounting rule
+	a      trust.Link // A[k, j]
+	dep    int        // index of R[i, k] equation in the row (-1 if it is not an equation of the row)
+	direct bool       // term is the direct referral trust (not discounted)
+}
+
+// collectTerms collects consensus terms of every equation of the rows
+func collectTerms(rows []row) error {
+	for ri := range rows {
+		r := &rows[ri]
+
+		index := make(map[trust.Link]int, len(r.eqs))
+		for i, eq := range r.eqs {
+			index[eq.R] = i
+		}
+
+		terms := make([][]newtonTerm, len(r.eqs))
+		for i, eq := range r.eqs {
+			c := &termsCollector{index: index}
+			if err := eq.Expression.Accept(c); err != nil {
+				return err
+			}
+			terms[i] = c.terms
+		}
+		r.terms = terms
+	}
+	return nil
+}
+
+// termsCollector collects terms of consensus of the expression
+type termsCollector struct {
+	index map[trust.Link]int
+	terms []newtonTerm
+}
+
+func (c *termsCollector) VisitFullUncertainty() error { return nil }
+
+func (c *termsCollector) VisitDiscountingRule(r trust.Link, a trust.Link) error {
+	dep, ok := c.index[r]
+	if !ok {
+		dep = -1
+	}
+	c.terms = append(c.terms, newtonTerm{r: r, a: a, dep: dep})
+	return nil
+}
+
+func (c *termsCollector) VisitDirectReferralTrust(a trust.Link) error {
+	c.terms = append(c.terms, newtonTerm{a: a, dep: -1, direct: true})
+	return nil
+}
+
+func (c *termsCollector) VisitConsensusListStart(count int) error { return nil }
+
+func (c *termsCollector) VisitConsensusList(index int, expression equations.FinalReferralTrustExpression) error {
+	return expression.Accept(c)
+}
+
+func (c *termsCollector) VisitConsensusListEnd() error { return nil }
+
+// newtonStep makes one damped Newton step for the equations of the row: solves (I - G'(x))·Δ = G(x) - x,
+// where x are current values of the equations and G evaluates expressions of the equations.
+func (s *system) newtonStep(context equations.FinalReferralTrustEquationContext, opts *options, r *row) error {
+	if isDone(s.done) {
+		return errCanceled
+	}
+
+	terms := r.terms
+	n := len(r.eqs)
+
+	x := make([]opinion.Type, n)
+	for i, eq := range r.eqs {
+		x[i] = context.GetFinalReferralTrust(eq.R)
+	}
+	gx := evaluateTerms(context, terms, x)
+	residual := residualNorm(x, gx)
+
+	next := gx // fixed-point iteration is used if Newton step fails
+	if delta, ok := newtonDirection(context, terms, x, gx); ok {
+		for i, step := 0, 1.0; i <= maxNewtonDampings; i, step = i+1, step/2 {
+			candidate, valid := applyNewtonStep(x, delta, step)
+			if !valid {
+				break
+			}
+			if residualNorm(candidate, evaluateTerms(context, terms, candidate)) < residual {
+				next = candidate
+				break
+			}
+		}
+	}
+
+	distanceFun := opts.distanceFun
+	onEquationEvaluated := opts.onEquationEvaluated
+	distances := s.distances[r.offset : r.offset+n]
+	for i, eq := range r.eqs {
+		context.SetFinalReferralTrust(eq.R, &next[i])
+
+		dist := distanceFun(&x[i], &next[i])
+		distances[i] = dist
+
+		if onEquationEvaluated != nil {
+			if err := onEquationEvaluated(eq.R, &x[i], &next[i], dist); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// evaluateTerms evaluates expressions of the row equations using values `x` of the row equations.
+// Every expression is a consensus of discounted opinions, it is evaluated the same way as EvaluateFinalReferralTrustExpression does:
+// α·y = U⊕(α·y) and x⊕y = x⊕(1·y).
+func evaluateTerms(context equations.FinalReferralTrustEquationContext, terms [][]newtonTerm, x []opinion.Type) []opinion.Type {
+	res := make([]opinion.Type, len(terms))
+	for i, eqTerms := range terms {
+		value := opinion.FullUncertainty()
+		for _, t := range eqTerms {
+			y := context.GetDirectReferralTrust(t.a)
+			value.PlusMul(termDiscount(context, &t, x), &y)
+		}
+		res[i] = value
+	}
+	return res
+}
+
+func termDiscount(context equations.FinalReferralTrustEquationContext, t *newtonTerm, x []opinion.Type) float64 {
+	switch {
+	case t.direct:
+		return 1
+	case t.dep >= 0:
+		return context.GetDiscount(x[t.dep])
+	default:
+		return context.GetDiscount(context.GetFinalReferralTrust(t.r))
+	}
+}
+
+// newtonDirection returns solution Δ of (I - G'(x))·Δ = G(x) - x as vector of belief and disbelief changes of every equation.
+// Scalar multiplication scales evidence of the opinion and consensus sums evidences, so consensus of discounted opinions
+// α[t]·y[t] is the opinion of evidence P = Σ α[t]·p[t], N = Σ α[t]·n[t] (evidence is measured in units of `c`):
+// B = P/S, D = N/S, where S = 1 + P + N. Its derivatives by α[t] are
+// ∂B/∂α[t] = (p[t]·(1 + N) - P·n[t])/S², ∂D/∂α[t] = (n[t]·(1 + P) - N·p[t])/S².
+// Derivatives of the discount function are found numerically.
+func newtonDirection(context equations.FinalReferralTrustEquationContext, terms [][]newtonTerm, x, gx []opinion.Type) ([]float64, bool) {
+	n := len(x)
+
+	// gradients of the discount of every equation value by belief and disbelief
+	gradients := make([][2]float64, n)
+	for i := range x {
+		gradients[i] = discountGradient(context, x[i])
+	}
+
+	// augmented matrix [I - G'(x) | G(x) - x]
+	m := make([][]float64, 2*n)
+	for i := range m {
+		m[i] = make([]float64, 2*n+1)
+		m[i][i] = 1
+	}
+
+	for i, eqTerms := range terms {
+		var pos, neg float64 // evidence of the consensus
+		for _, t := range eqTerms {
+			y := context.GetDirectReferralTrust(t.a)
+			if y.U <= 0 {
+				return nil, false // infinite evidence
+			}
+			alpha := termDiscount(context, &t, x)
+			pos += alpha * y.B / y.U
+			neg += alpha * y.D / y.U
+		}
+		s2 := (1 + pos + neg) * (1 + pos + neg)
+
+		for _, t := range eqTerms {
+			if t.dep < 0 {
+				continue // discount does not depend on the row equations
+			}
+			y := context.GetDirectReferralTrust(t.a)
+			tp, tn := y.B/y.U, y.D/y.U
+			dB := (tp*(1+neg) - pos*tn) / s2
+			dD := (tn*(1+pos) - neg*tp) / s2
+
+			grad := &gradients[t.dep]
+			m[2*i][2*t.dep] -= dB * grad[0]
+			m[2*i][2*t.dep+1] -= dB * grad[1]
+			m[2*i+1][2*t.dep] -= dD * grad[0]
+			m[2*i+1][2*t.dep+1] -= dD * grad[1]
+		}
+
+		m[2*i][2*n] = gx[i].B - x[i].B
+		m[2*i+1][2*n] = gx[i].D - x[i].D
+	}
+
+	return solveLinear(m)
+}
+
+// discountGradient returns derivatives of the discount by belief and disbelief of the opinion,
+// uncertainty changes to keep the sum of the components equal to one.
+// Gradient of the context is used if it is known (see equations.DiscountGradientContext),
+// otherwise it is approximated by finite differences.
+func discountGradient(context equations.FinalReferralTrustEquationContext, o opinion.Type) (res [2]float64) {
+	if gc, ok := context.(equations.DiscountGradientContext); ok {
+		if dB, dD, ok := gc.GetDiscountGradient(o); ok {
+			return [2]float64{dB, dD}
+		}
+	}
+
+	const h = discountDerivativeStep
+	g := context.GetDiscount(o)
+
+	derivative := func(component float64, move func(o *opinion.Type, h float64)) float64 {
+		forward, backward := o.U >= h, component >= h
+		fx := func(h float64) float64 {
+			v := o
+			move(&v, h)
+			return context.GetDiscount(v)
+		}
+		switch {
+		case forward && backward:
+			return (fx(h) - fx(-h)) / (2 * h)
+		case forward:
+			return (fx(h) - g) / h
+		case backward:
+			return (g - fx(-h)) / h
+		default:
+			return 0
+		}
+	}
+
+	res[0] = derivative(o.B, func(o *opinion.Type, h float64) { o.B += h; o.U -= h })
+	res[1] = derivative(o.D, func(o *opinion.Type, h float64) { o.D += h; o.U -= h })
+	return
+}
+
+// applyNewtonStep returns x + step·Δ and false if it is not a vector of valid opinions
+func applyNewtonStep(x []opinion.Type, delta []float64, step float64) ([]opinion.Type, bool) {
+	res := make([]opinion.Type, len(x))
+	for i := range x {
+		b := x[i].B + step*delta[2*i]
+		d := x[i].D + step*delta[2*i+1]
+		if !(b >= 0 && d >= 0 && b+d <= 1) { // also false for NaN
+			return nil, false
+		}
+		res[i] = opinion.Type{B: b, D: d, U: 1 - b - d}
+	}
+	return res, true
+}
+
+// residualNorm returns Euclidean norm of G(x) - x
+func residualNorm(x, gx []opinion.Type) float64 {
+	var res float64
+	for i := range x {
+		db := gx[i].B - x[i].B
+		dd := gx[i].D - x[i].D
+		res += db*db + dd*dd
+	}
+	return math.Sqrt(res)
+}
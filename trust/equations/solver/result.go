@@ -0,0 +1,50 @@
+package solver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dimchansky/ebsl-go/trust"
+)
+
+// Result of solving equations
+type Result struct {
+	// Converged is true if aggregated distance reached the tolerance
+	Converged bool
+	// Epochs is the number of completed epochs
+	Epochs uint
+	// Residuals contains aggregated distance of every completed epoch
+	Residuals []float64
+	// SlowestLinks are links of the equations with the largest distance at their last evaluation, sorted by distance descending
+	SlowestLinks []LinkDistance
+	// WallTime is the time spent on solving
+	WallTime time.Duration
+	// DirectEquations is the number of equations solved exactly by a single evaluation
+	DirectEquations int
+	// IterativeEquations is the number of equations solved iteratively
+	IterativeEquations int
+}
+
+// LinkDistance is a distance between two last evaluated values of the equation
+type LinkDistance struct {
+	Link     trust.Link
+	Distance float64
+}
+
+// Residual returns aggregated distance of the last completed epoch (+Inf if no epoch was completed)
+func (r *Result) Residual() float64 {
+	if len(r.Residuals) == 0 {
+		return inf
+	}
+	return r.Residuals[len(r.Residuals)-1]
+}
+
+// String implements fmt.Stringer
+func (r *Result) String() string {
+	status := "converged"
+	if !r.Converged {
+		status = "not converged"
+	}
+	return fmt.Sprintf("%v after %v epochs in %v, residual: %v, equations solved directly: %v, iteratively: %v",
+		status, r.Epochs, r.WallTime, r.Residual(), r.DirectEquations, r.IterativeEquations)
+}
@@ -1,15 +1,24 @@
 package solver
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"math"
+	"time"
 
 	"github.com/dimchansky/ebsl-go/opinion"
+	"github.com/dimchansky/ebsl-go/trust"
 	"github.com/dimchansky/ebsl-go/trust/equations"
 )
 
 var (
-	ErrEpochMustBePositiveNumber = errors.New("solver: epoch must be positive number")
+	ErrEpochMustBePositiveNumber   = errors.New("solver: epoch must be positive number")
+	ErrWorkersMustBePositiveNumber = errors.New("solver: number of workers must be positive number")
+	ErrDepthMustBePositiveNumber   = errors.New("solver: acceleration depth must be positive number")
+	ErrNewtonOptionsConflict       = errors.New("solver: Newton method cannot be used with Jacobi update mode or Anderson acceleration")
+	ErrEquationsOptionsNotUsed     = errors.New("solver: equations options are used only by solvers that create equations themselves")
+	ErrContextIsNotConcurrent      = errors.New("solver: context must be safe for concurrent use when several workers update it")
 )
 
 type DistanceFun func(prevValue *opinion.Type, newValue *opinion.Type) float64
@@ -21,17 +30,46 @@ type DistanceAggregator interface {
 }
 
 type EpochStartFun func(epoch uint) error
+
 type EpochEndFun func(epoch uint, aggregatedDistance float64) error
 
+// ActiveEquationsFun is called at the end of every epoch with number of equations that remain active
+// (will be evaluated in the next epoch)
+type ActiveEquationsFun func(epoch uint, activeEquations int) error
+
+// EquationEvaluatedFun is called after every evaluation of the equation with its link, previous and new values and distance between them
+type EquationEvaluatedFun func(link trust.Link, prevValue *opinion.Type, newValue *opinion.Type, distance float64) error
+
 type options struct {
-	epochs             uint
-	distanceFun        DistanceFun
-	distanceAggregator DistanceAggregator
-	tolerance          float64
-	onEpochStart       EpochStartFun
-	onEpochEnd         EpochEndFun
+	epochs              uint
+	distanceFun         DistanceFun
+	distanceAggregator  DistanceAggregator
+	tolerance           float64
+	onEpochStart        EpochStartFun
+	onEpochEnd          EpochEndFun
+	onActiveEquations   ActiveEquationsFun
+	workers             uint
+	perSource           bool
+	slowestLinks        int
+	onEquationEvaluated EquationEvaluatedFun
+	updateMode          UpdateMode
+	accelerationDepth   uint
+	equationsOptions    []equations.EquationsOption
+	newton              bool // set by Newton solver only
 }
 
+// UpdateMode defines when evaluated values become visible to other equations
+type UpdateMode int
+
+const (
+	// GaussSeidelUpdate writes every evaluated value to the context immediately,
+	// so equations evaluated later in the same epoch use it (result depends on the order of equations)
+	GaussSeidelUpdate UpdateMode = iota
+	// JacobiUpdate evaluates all equations of the epoch using values of the previous epoch
+	// and writes evaluated values to the context at the end of the epoch
+	JacobiUpdate
+)
+
 type Options func(opts *options) (*options, error)
 
 func UseMaxEpochs(epochs uint) Options {
@@ -86,77 +124,275 @@ func UseOnEpochEndCallback(onEpochEnd EpochEndFun) Options {
 	}
 }
 
+// UseOnActiveEquationsCallback sets callback that is called at the end of every epoch before epoch end callback,
+// number of active equations decreases only if UsePerSourceConvergence is used.
+func UseOnActiveEquationsCallback(onActiveEquations ActiveEquationsFun) Options {
+	return func(opts *options) (*options, error) {
+		opts.onActiveEquations = onActiveEquations
+		return opts, nil
+	}
+}
+
+// UseOnEquationEvaluatedCallback sets callback that is called after every evaluation of every equation.
+// Callback is called concurrently from different goroutines when more than one worker is used.
+func UseOnEquationEvaluatedCallback(onEquationEvaluated EquationEvaluatedFun) Options {
+	return func(opts *options) (*options, error) {
+		opts.onEquationEvaluated = onEquationEvaluated
+		return opts, nil
+	}
+}
+
+// UseUpdateMode sets update mode of the solver (GaussSeidelUpdate by default).
+// In JacobiUpdate mode context is only read while equations are evaluated, so context that is safe for
+// concurrent reads is enough to use several workers.
+func UseUpdateMode(updateMode UpdateMode) Options {
+	return func(opts *options) (*options, error) {
+		opts.updateMode = updateMode
+		return opts, nil
+	}
+}
+
+// UseAndersonAcceleration enables Anderson mixing of the last `depth` epochs: after every epoch values of the equations
+// of every source node are replaced with the combination of the recent evaluated values that minimizes the distance
+// between epochs. Combination that does not give valid opinions is rejected and mixing of the source node restarts.
+func UseAndersonAcceleration(depth uint) Options {
+	return func(opts *options) (*options, error) {
+		if depth == 0 {
+			return nil, ErrDepthMustBePositiveNumber
+		}
+		opts.accelerationDepth = depth
+		return opts, nil
+	}
+}
+
+// UseWorkers sets number of goroutines that evaluate equations in parallel (1 by default).
+// Equations are split between workers by source node, so context must be safe for concurrent use
+// (implement equations.ConcurrentContext) when more than one worker is used, unless JacobiUpdate mode is used.
+// Solving returns ErrContextIsNotConcurrent otherwise.
+func UseWorkers(workers uint) Options {
+	return func(opts *options) (*options, error) {
+		if workers < 1 {
+			return nil, ErrWorkersMustBePositiveNumber
+		}
+		opts.workers = workers
+		return opts, nil
+	}
+}
+
+// UsePerSourceConvergence makes solver to track convergence of every source node separately:
+// once aggregated distance of equations of some source node is within tolerance, they are not evaluated anymore.
+// Equations of different source nodes do not depend on each other, so converged rows stay converged.
+func UsePerSourceConvergence() Options {
+	return func(opts *options) (*options, error) {
+		opts.perSource = true
+		return opts, nil
+	}
+}
+
+// UseSlowestLinksCount sets number of the slowest converging links reported in the result (10 by default)
+func UseSlowestLinksCount(count int) Options {
+	return func(opts *options) (*options, error) {
+		opts.slowestLinks = count
+		return opts, nil
+	}
+}
+
+// UseEquationsOptions sets options of the equations created by the solver itself
+// (see SolveFinalReferralTrustIncrementally and SolveFinalReferralTrustOfSources).
+// Solvers of the given equations return ErrEquationsOptionsNotUsed if it is used.
+func UseEquationsOptions(eqsOpts ...equations.EquationsOption) Options {
+	return func(opts *options) (*options, error) {
+		opts.equationsOptions = append(opts.equationsOptions, eqsOpts...)
+		return opts, nil
+	}
+}
+
+// CanceledError is returned when solving is stopped because context is canceled or its deadline is exceeded
+type CanceledError struct {
+	// Epoch is the epoch during which solving was stopped (0 if it was stopped before the first epoch)
+	Epoch uint
+	// Residual is aggregated distance of equations: distances of the interrupted epoch for evaluated equations and
+	// of the previous epoch for the rest (equations that were never evaluated have infinite distance)
+	Residual float64
+	// Err is the error of the context
+	Err error
+}
+
+// Error implements error interface
+func (e *CanceledError) Error() string {
+	return fmt.Sprintf("solver: stopped at epoch %v with residual %v: %v", e.Epoch, e.Residual, e.Err)
+}
+
+// Unwrap returns the error of the context
+func (e *CanceledError) Unwrap() error { return e.Err }
+
+// SolveFinalReferralTrustEquations solves final referral trust equations
+// (see SolveFinalReferralTrustEquationsWithResult to get the result of solving)
 func SolveFinalReferralTrustEquations(
-	context equations.FinalReferralTrustEquationContext,
+	frtContext equations.FinalReferralTrustEquationContext,
 	eqs equations.IterableFinalReferralTrustEquations,
 	opts ...Options,
-) (err error) {
-	solverOpts := &options{
-		epochs:             100,
-		distanceFun:        manhattanDistance,
-		distanceAggregator: &maxDistanceAggregator{},
-		tolerance:          0.0,
-		onEpochStart: func(epoch uint) error {
-			return nil
-		},
-		onEpochEnd: func(epoch uint, aggregatedDistance float64) error {
-			return nil
-		},
-	}
+) error {
+	_, err := SolveFinalReferralTrustEquationsWithResult(frtContext, eqs, opts...)
+	return err
+}
 
-	// apply solver options
-	for _, applyOption := range opts {
-		solverOpts, err = applyOption(solverOpts)
-		if err != nil {
-			return
-		}
+// SolveFinalReferralTrustEquationsWithResult solves final referral trust equations and returns the result of solving
+func SolveFinalReferralTrustEquationsWithResult(
+	frtContext equations.FinalReferralTrustEquationContext,
+	eqs equations.IterableFinalReferralTrustEquations,
+	opts ...Options,
+) (*Result, error) {
+	return SolveFinalReferralTrustEquationsWithContext(context.Background(), frtContext, eqs, opts...)
+}
+
+// SolveFinalReferralTrustEquationsWithContext solves final referral trust equations until the context is done.
+// Cancellation is checked before evaluation of every equation, *CanceledError is returned if the context is done.
+// Result is returned even if solving is stopped by an error, unless the error occurred before the first epoch.
+func SolveFinalReferralTrustEquationsWithContext(
+	ctx context.Context,
+	frtContext equations.FinalReferralTrustEquationContext,
+	eqs equations.IterableFinalReferralTrustEquations,
+	opts ...Options,
+) (*Result, error) {
+	startTime := time.Now()
+
+	solverOpts, err := newOptionsOfGivenEquations(opts)
+	if err != nil {
+		return nil, err
 	}
 
+	return solve(ctx, frtContext, eqs, solverOpts, startTime)
+}
+
+func solve(
+	ctx context.Context,
+	frtContext equations.FinalReferralTrustEquationContext,
+	eqs equations.IterableFinalReferralTrustEquations,
+	solverOpts *options,
+	startTime time.Time,
+) (*Result, error) {
 	epochs := solverOpts.epochs
-	distanceFun := solverOpts.distanceFun
 	distanceAggregator := solverOpts.distanceAggregator
 	tolerance := solverOpts.tolerance
 	onEpochStart := solverOpts.onEpochStart
 	onEpochEnd := solverOpts.onEpochEnd
 
+	if err := checkConcurrency(frtContext, solverOpts); err != nil {
+		return nil, err
+	}
+
+	sys, err := newSystem(ctx.Done(), eqs, solverOpts)
+	if err == errCanceled {
+		return nil, &CanceledError{Epoch: 0, Residual: math.Inf(1), Err: ctx.Err()}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{IterativeEquations: len(sys.distances)}
+	defer func() {
+		res.SlowestLinks = sys.slowestLinks(solverOpts.slowestLinks)
+		res.WallTime = time.Since(startTime)
+	}()
+
 	for epoch := uint(1); epoch <= epochs; epoch++ {
 		if err := onEpochStart(epoch); err != nil {
-			return err
+			return res, err
 		}
 
-		distanceAggregator.Reset()
+		err = sys.evaluate(frtContext, solverOpts)
+		if err == nil && solverOpts.updateMode == JacobiUpdate {
+			sys.applyNextValues(frtContext)
+		}
+		if err == errCanceled {
+			return res, &CanceledError{Epoch: epoch, Residual: sys.aggregateDistances(distanceAggregator), Err: ctx.Err()}
+		}
+		if err != nil {
+			return res, err
+		}
 
-		foreachEquation := eqs.GetFinalReferralTrustEquationIterator()
-		err = foreachEquation(func(eq *equations.FinalReferralTrustEquation) error {
-			prevValue := context.GetFinalReferralTrust(eq.R)
-			newValue, err := eq.EvaluateFinalReferralTrust(context)
-			if err != nil {
-				return err
-			}
-			dist := distanceFun(&prevValue, newValue)
+		distError := sys.aggregateDistances(distanceAggregator)
+		if solverOpts.perSource {
+			sys.deactivateConvergedRows(distanceAggregator, tolerance)
+		}
+		activeEquations := sys.activeEquations()
 
-			// fmt.Printf("R[%v,%v]: prev: %v new: %v dist: %v\n", eq.R.From, eq.R.To, prevValue, newValue, dist) // TODO: add callback
+		res.Epochs = epoch
+		res.Residuals = append(res.Residuals, distError)
+		res.Converged = distError <= tolerance || activeEquations == 0
 
-			distanceAggregator.Add(dist)
-			return nil
-		})
-		if err != nil {
-			return
+		if solverOpts.onActiveEquations != nil {
+			if err := solverOpts.onActiveEquations(epoch, activeEquations); err != nil {
+				return res, err
+			}
 		}
-
-		distError := distanceAggregator.Result()
 		if err := onEpochEnd(epoch, distError); err != nil {
-			return err
+			return res, err
 		}
 
-		if distError <= tolerance {
-			return nil
+		if res.Converged {
+			return res, nil
+		}
+
+		if sys.anderson != nil {
+			sys.mix(frtContext)
 		}
 	}
 
+	return res, nil
+}
+
+// checkConcurrency checks that context can be updated by several workers if they are used
+func checkConcurrency(frtContext equations.FinalReferralTrustEquationContext, solverOpts *options) error {
+	if solverOpts.workers <= 1 || solverOpts.updateMode == JacobiUpdate {
+		return nil
+	}
+	if _, ok := frtContext.(equations.ConcurrentContext); !ok {
+		return ErrContextIsNotConcurrent
+	}
 	return nil
 }
 
+// newOptionsOfGivenEquations returns options of the solver of equations created by the caller,
+// such solver does not use equations options
+func newOptionsOfGivenEquations(opts []Options) (*options, error) {
+	solverOpts, err := newOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(solverOpts.equationsOptions) > 0 {
+		return nil, ErrEquationsOptionsNotUsed
+	}
+	return solverOpts, nil
+}
+
+func newOptions(opts []Options) (solverOpts *options, err error) {
+	solverOpts = &options{
+		epochs:             100,
+		distanceFun:        manhattanDistance,
+		distanceAggregator: &maxDistanceAggregator{},
+		tolerance:          0.0,
+		onEpochStart: func(epoch uint) error {
+			return nil
+		},
+		onEpochEnd: func(epoch uint, aggregatedDistance float64) error {
+			return nil
+		},
+		workers:      1,
+		slowestLinks: 10,
+	}
+
+	// apply solver options
+	for _, applyOption := range opts {
+		solverOpts, err = applyOption(solverOpts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return solverOpts, nil
+}
+
 func manhattanDistance(prevValue *opinion.Type, newValue *opinion.Type) float64 {
 	return math.Abs(prevValue.B-newValue.B) +
 		math.Abs(prevValue.D-newValue.D) +
@@ -1,7 +1,10 @@
 package solver_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"testing"
 
 	"github.com/dimchansky/ebsl-go/evidence"
@@ -92,6 +95,700 @@ func TestSolveFinalReferralTrustEquations(t *testing.T) {
 	}
 }
 
+// solveTests are cases of TestSolveFinalReferralTrustEquations shared by the tests of other solving methods
+var solveTests = []struct {
+	name string
+	dro  trust.DirectReferralOpinion
+	want trust.FinalReferralOpinion
+}{
+	{"1",
+		trust.DirectReferralEvidence{
+			trust.Link{From: 1, To: 2}: evidence.New(2, 2),
+			trust.Link{From: 2, To: 3}: evidence.New(2, 2),
+			trust.Link{From: 3, To: 2}: evidence.New(2, 2),
+		}.ToDirectReferralOpinion(2),
+		trust.FinalReferralOpinion{
+			trust.Link{From: 1, To: 2}: opinion.New(0.3535533905932738, 0.3535533905932738, 0.2928932188134525),
+			trust.Link{From: 1, To: 3}: opinion.New(0.20710678118654752, 0.20710678118654752, 0.585786437626905),
+			trust.Link{From: 2, To: 3}: opinion.New(0.3333333333333333, 0.3333333333333333, 0.3333333333333333),
+			trust.Link{From: 3, To: 2}: opinion.New(0.3333333333333333, 0.3333333333333333, 0.3333333333333333),
+		},
+	},
+	{"2",
+		trust.DirectReferralEvidence{
+			trust.Link{From: 1, To: 2}: evidence.New(400, 300),
+			trust.Link{From: 2, To: 3}: evidence.New(10, 5),
+			trust.Link{From: 3, To: 4}: evidence.New(500, 0),
+			trust.Link{From: 3, To: 5}: evidence.New(500, 0),
+			trust.Link{From: 4, To: 5}: evidence.New(500, 0),
+			trust.Link{From: 4, To: 6}: evidence.New(500, 0),
+			trust.Link{From: 5, To: 6}: evidence.New(500, 0),
+			trust.Link{From: 6, To: 7}: evidence.New(5, 5),
+		}.ToDirectReferralOpinion(2),
+		trust.FinalReferralOpinion{
+			trust.Link{From: 1, To: 2}: opinion.New(0.5698005698005698, 0.42735042735042733, 0.002849002849002849),
+			trust.Link{From: 1, To: 3}: opinion.New(0.5402485143165856, 0.2701242571582928, 0.18962722852512154),
+			trust.Link{From: 1, To: 4}: opinion.New(0.9926504163175847, 0, 0.007349583682415396),
+			trust.Link{From: 1, To: 5}: opinion.New(0.9973973565077897, 0, 0.002602643492210305),
+			trust.Link{From: 1, To: 6}: opinion.New(0.9979940300054436, 0, 0.0020059699945565415),
+			trust.Link{From: 1, To: 7}: opinion.New(0.4165271299394158, 0.4165271299394158, 0.1669457401211684),
+			trust.Link{From: 2, To: 3}: opinion.New(0.5882352941176471, 0.29411764705882354, 0.11764705882352941),
+			trust.Link{From: 2, To: 4}: opinion.New(0.9932459276916965, 0, 0.006754072308303535),
+			trust.Link{From: 2, To: 5}: opinion.New(0.9974771066695858, 0, 0.0025228933304143578),
+			trust.Link{From: 2, To: 6}: opinion.New(0.9979947090743448, 0, 0.0020052909256551574),
+			trust.Link{From: 2, To: 7}: opinion.New(0.4165271772550089, 0.4165271772550089, 0.16694564548998223),
+			trust.Link{From: 3, To: 4}: opinion.New(0.9960159362549801, 0, 0.00398406374501992),
+			trust.Link{From: 3, To: 5}: opinion.New(0.998000015936128, 0, 0.001999984063872001),
+			trust.Link{From: 3, To: 6}: opinion.New(0.9979980139820138, 0, 0.0020019860179862087),
+			trust.Link{From: 3, To: 7}: opinion.New(0.4165274075308264, 0.4165274075308264, 0.16694518493834723),
+			trust.Link{From: 4, To: 5}: opinion.New(0.9960159362549801, 0, 0.00398406374501992),
+			trust.Link{From: 4, To: 6}: opinion.New(0.998000015936128, 0, 0.001999984063872001),
+			trust.Link{From: 4, To: 7}: opinion.New(0.4165275470202235, 0.4165275470202235, 0.16694490595955308),
+			trust.Link{From: 5, To: 6}: opinion.New(0.9960159362549801, 0, 0.00398406374501992),
+			trust.Link{From: 5, To: 7}: opinion.New(0.4163890739506996, 0.4163890739506996, 0.1672218520986009),
+			trust.Link{From: 6, To: 7}: opinion.New(0.4166666666666667, 0.4166666666666667, 0.16666666666666666),
+		},
+	},
+}
+
+func TestSolveFinalReferralTrustEquationsInParallel(t *testing.T) {
+	for _, tt := range solveTests {
+		t.Run(tt.name, func(t *testing.T) {
+			eqs := equations.CreateFinalReferralTrustEquations(tt.dro)
+
+			context := equations.NewConcurrentFinalReferralTrustEquationContext(tt.dro)
+
+			if err := solver.SolveFinalReferralTrustEquations(
+				context,
+				eqs,
+				solver.UseWorkers(4),
+			); err != nil {
+				t.Fatal(err)
+			}
+
+			got := context.FinalReferralTrust()
+
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Errorf("SolveFinalReferralTrustEquations: %v", diff)
+			}
+		})
+	}
+}
+
+func TestSolveFinalReferralTrustEquationsInParallelRequiresConcurrentContext(t *testing.T) {
+	tt := solveTests[0]
+	eqs := equations.CreateFinalReferralTrustEquations(tt.dro)
+
+	err := solver.SolveFinalReferralTrustEquations(
+		equations.NewDefaultFinalReferralTrustEquationContext(tt.dro),
+		eqs,
+		solver.UseWorkers(4),
+	)
+	if err != solver.ErrContextIsNotConcurrent {
+		t.Errorf("got error %v, want %v", err, solver.ErrContextIsNotConcurrent)
+	}
+}
+
+func TestSolveFinalReferralTrustEquationsWithPerSourceConvergence(t *testing.T) {
+	for _, tt := range solveTests {
+		t.Run(tt.name, func(t *testing.T) {
+			eqs := equations.CreateFinalReferralTrustEquations(tt.dro)
+
+			context := equations.NewDefaultFinalReferralTrustEquationContext(tt.dro)
+
+			var activeHistory []int
+			if err := solver.SolveFinalReferralTrustEquations(
+				context,
+				eqs,
+				solver.UsePerSourceConvergence(),
+				solver.UseOnActiveEquationsCallback(func(epoch uint, activeEquations int) error {
+					activeHistory = append(activeHistory, activeEquations)
+					return nil
+				}),
+			); err != nil {
+				t.Fatal(err)
+			}
+
+			if last := activeHistory[len(activeHistory)-1]; last != 0 {
+				t.Errorf("active equations at the last epoch: got %v, want 0", last)
+			}
+			for i := 1; i < len(activeHistory); i++ {
+				if activeHistory[i] > activeHistory[i-1] {
+					t.Errorf("number of active equations grows: %v", activeHistory)
+				}
+			}
+
+			got := context.FinalReferralTrust
+
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Errorf("SolveFinalReferralTrustEquations: %v", diff)
+			}
+		})
+	}
+}
+
+func TestSolveFinalReferralTrustEquationsWithJacobiUpdate(t *testing.T) {
+	for _, tt := range solveTests {
+		t.Run(tt.name, func(t *testing.T) {
+			eqs := equations.CreateFinalReferralTrustEquations(tt.dro)
+
+			// context is only read during epoch in Jacobi mode, so default context can be used by several workers
+			context := equations.NewDefaultFinalReferralTrustEquationContext(tt.dro)
+
+			if err := solver.SolveFinalReferralTrustEquations(
+				context,
+				eqs,
+				solver.UseUpdateMode(solver.JacobiUpdate),
+				solver.UseWorkers(4),
+			); err != nil {
+				t.Fatal(err)
+			}
+
+			got := context.FinalReferralTrust
+
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Errorf("SolveFinalReferralTrustEquations: %v", diff)
+			}
+		})
+	}
+}
+
+func TestSolveFinalReferralTrustEquationsWithAndersonAcceleration(t *testing.T) {
+	for _, mode := range []solver.UpdateMode{solver.GaussSeidelUpdate, solver.JacobiUpdate} {
+		for _, tt := range solveTests {
+			t.Run(tt.name, func(t *testing.T) {
+				eqs := equations.CreateFinalReferralTrustEquations(tt.dro)
+				context := equations.NewDefaultFinalReferralTrustEquationContext(tt.dro)
+
+				if err := solver.SolveFinalReferralTrustEquations(
+					context,
+					eqs,
+					solver.UseUpdateMode(mode),
+					solver.UseAndersonAcceleration(3),
+					solver.UseOnEquationEvaluatedCallback(func(link trust.Link, prevValue, newValue *opinion.Type, distance float64) error {
+						// mixed values must be valid opinions
+						if prevValue.B < 0 || prevValue.D < 0 || prevValue.U < 0 || math.Abs(prevValue.B+prevValue.D+prevValue.U-1) > 1e-12 {
+							return fmt.Errorf("invalid opinion of %v: %v", link, prevValue)
+						}
+						return nil
+					}),
+				); err != nil {
+					t.Fatal(err)
+				}
+
+				got := context.FinalReferralTrust
+
+				if diff := deep.Equal(got, tt.want); diff != nil {
+					t.Errorf("SolveFinalReferralTrustEquations: %v", diff)
+				}
+			})
+		}
+	}
+
+	t.Run("invalid depth", func(t *testing.T) {
+		dro := completeGraph(2)
+		err := solver.SolveFinalReferralTrustEquations(
+			equations.NewDefaultFinalReferralTrustEquationContext(dro),
+			equations.CreateFinalReferralTrustEquations(dro),
+			solver.UseAndersonAcceleration(0),
+		)
+		if err != solver.ErrDepthMustBePositiveNumber {
+			t.Errorf("got error %v, want %v", err, solver.ErrDepthMustBePositiveNumber)
+		}
+	})
+}
+
+func TestSolveFinalReferralTrustEquationsNewton(t *testing.T) {
+	for _, workers := range []uint{1, 4} {
+		for _, tt := range solveTests {
+			t.Run(tt.name, func(t *testing.T) {
+				eqs := equations.CreateFinalReferralTrustEquations(tt.dro)
+				context := equations.NewConcurrentFinalReferralTrustEquationContext(tt.dro)
+
+				res, err := solver.SolveFinalReferralTrustEquationsNewton(
+					context,
+					eqs,
+					solver.UseWorkers(workers),
+					solver.UseTolerance(1e-14),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !res.Converged {
+					t.Errorf("equations did not converge: %v", res)
+				}
+
+				got := context.FinalReferralTrust()
+
+				if diff := deep.Equal(got, tt.want); diff != nil {
+					t.Errorf("SolveFinalReferralTrustEquationsNewton: %v", diff)
+				}
+			})
+		}
+	}
+
+	t.Run("quadratic convergence", func(t *testing.T) {
+		dro := completeGraph(6)
+		eqs := equations.CreateFinalReferralTrustEquations(dro)
+
+		fixedPoint, err := solver.SolveFinalReferralTrustEquationsWithResult(
+			equations.NewDefaultFinalReferralTrustEquationContext(dro),
+			eqs,
+			solver.UseTolerance(1e-12),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		newton, err := solver.SolveFinalReferralTrustEquationsNewton(
+			equations.NewDefaultFinalReferralTrustEquationContext(dro),
+			eqs,
+			solver.UseTolerance(1e-12),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !newton.Converged || newton.Epochs >= fixedPoint.Epochs {
+			t.Errorf("got %v, want to converge in less than %v epochs", newton, fixedPoint.Epochs)
+		}
+	})
+
+	t.Run("conflicting options", func(t *testing.T) {
+		dro := solveTests[0].dro
+		eqs := equations.CreateFinalReferralTrustEquations(dro)
+
+		for _, opt := range []solver.Options{
+			solver.UseUpdateMode(solver.JacobiUpdate),
+			solver.UseAndersonAcceleration(2),
+		} {
+			_, err := solver.SolveFinalReferralTrustEquationsNewton(equations.NewDefaultFinalReferralTrustEquationContext(dro), eqs, opt)
+			if err != solver.ErrNewtonOptionsConflict {
+				t.Errorf("got error %v, want %v", err, solver.ErrNewtonOptionsConflict)
+			}
+		}
+	})
+}
+
+func TestSolveFinalReferralTrustEquationBlocks(t *testing.T) {
+	wantCounts := map[string][2]int{
+		"1": {2, 2},  // R[1,2] and R[1,3] depend on each other
+		"2": {21, 0}, // graph is acyclic
+	}
+
+	for _, tt := range solveTests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks := equations.CreateFinalReferralTrustEquationBlocks(tt.dro)
+			context := equations.NewDefaultFinalReferralTrustEquationContext(tt.dro)
+
+			res, err := solver.SolveFinalReferralTrustEquationBlocks(context, blocks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !res.Converged {
+				t.Errorf("equations did not converge: %v", res)
+			}
+			if got, want := [2]int{res.DirectEquations, res.IterativeEquations}, wantCounts[tt.name]; got != want {
+				t.Errorf("direct and iterative equations: got %v, want %v", got, want)
+			}
+
+			got := context.FinalReferralTrust
+
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Errorf("SolveFinalReferralTrustEquationBlocks: %v", diff)
+			}
+		})
+	}
+}
+
+func TestSolveFinalReferralTrustEquationsWithInitialization(t *testing.T) {
+	for _, tt := range solveTests {
+		initializations := []struct {
+			name           string
+			initialization equations.Initialization
+		}{
+			{"full belief", equations.FullBeliefInitialization},
+			{"full uncertainty", equations.FullUncertaintyInitialization},
+			{"direct trust", equations.DirectTrustInitialization(tt.dro)},
+			{"solution", equations.SolutionInitialization(tt.want, equations.FullBeliefInitialization)},
+			{"topological estimate", equations.TopologicalEstimateInitialization(tt.dro)},
+			{"topological estimate of blocks", equations.TopologicalEstimateInitializationOfBlocks(tt.dro,
+				equations.CreateFinalReferralTrustEquationBlocks(tt.dro, equations.UseSources(1)))},
+		}
+		for _, init := range initializations {
+			t.Run(tt.name+"/"+init.name, func(t *testing.T) {
+				eqs := equations.CreateFinalReferralTrustEquations(tt.dro)
+				context := equations.NewDefaultFinalReferralTrustEquationContext(tt.dro, equations.UseInitialization(init.initialization))
+
+				res, err := solver.SolveFinalReferralTrustEquationsWithResult(context, eqs, solver.UseTolerance(1e-14))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !res.Converged {
+					t.Errorf("equations did not converge: %v", res)
+				}
+
+				// the same fixed point is reached from every initial value
+				got := context.FinalReferralTrust
+
+				if diff := deep.Equal(got, tt.want); diff != nil {
+					t.Errorf("SolveFinalReferralTrustEquations: %v", diff)
+				}
+			})
+		}
+	}
+}
+
+func TestSolveFinalReferralTrustOfSources(t *testing.T) {
+	for _, tt := range solveTests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources := []uint64{1, 3}
+
+			got, res, err := solver.SolveFinalReferralTrustOfSources(
+				context.Background(),
+				equations.NewDefaultFinalReferralTrustEquationContext(tt.dro),
+				tt.dro,
+				sources,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !res.Converged {
+				t.Errorf("equations did not converge: %v", res)
+			}
+
+			want := make(trust.FinalReferralOpinion)
+			for link, value := range tt.want {
+				if link.From == sources[0] || link.From == sources[1] {
+					want[link] = value
+				}
+			}
+
+			if diff := deep.Equal(got, want); diff != nil {
+				t.Errorf("SolveFinalReferralTrustOfSources: %v", diff)
+			}
+		})
+	}
+}
+
+func TestSolveFinalReferralTrustIncrementally(t *testing.T) {
+	dre := trust.DirectReferralEvidence{}
+	for i := uint64(1); i < 8; i++ {
+		dre[trust.Link{From: i, To: i + 1}] = evidence.New(float64(i), 1)
+		dre[trust.Link{From: i + 1, To: i}] = evidence.New(1, float64(i))
+	}
+	dro := dre.ToDirectReferralOpinion(2)
+
+	frtContext := equations.NewDefaultFinalReferralTrustEquationContext(dro)
+	if err := solver.SolveFinalReferralTrustEquations(frtContext, equations.CreateFinalReferralTrustEquations(dro)); err != nil {
+		t.Fatal(err)
+	}
+	total := len(frtContext.FinalReferralTrust)
+
+	delta := &trust.DirectReferralOpinionDelta{
+		Updated: trust.DirectReferralEvidence{
+			trust.Link{From: 5, To: 6}: evidence.New(10, 0), // updated
+			trust.Link{From: 8, To: 9}: evidence.New(3, 1),  // added
+		}.ToDirectReferralOpinion(2),
+		Removed: trust.Links{
+			{From: 3, To: 2},
+			{From: 2, To: 1},
+		},
+	}
+
+	// the change is applied to dro (direct referral trust of the context) in place
+	res, err := solver.SolveFinalReferralTrustIncrementally(context.Background(), frtContext, dro, delta,
+		solver.UseTolerance(1e-15), solver.UseEquationsOptions(equations.UseSortedOrder()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Converged {
+		t.Errorf("equations did not converge: %v", res)
+	}
+	if res.IterativeEquations >= total {
+		t.Errorf("got %v solved equations, want less than %v", res.IterativeEquations, total)
+	}
+
+	want := equations.NewDefaultFinalReferralTrustEquationContext(dro)
+	if err := solver.SolveFinalReferralTrustEquations(want, equations.CreateFinalReferralTrustEquations(dro)); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := deep.Equal(frtContext.FinalReferralTrust, want.FinalReferralTrust); diff != nil {
+		t.Errorf("SolveFinalReferralTrustIncrementally: %v", diff)
+	}
+}
+
+func TestSolversOfGivenEquationsRejectEquationsOptions(t *testing.T) {
+	dro := solveTests[0].dro
+	opt := solver.UseEquationsOptions(equations.UseSortedOrder())
+
+	solvers := []struct {
+		name  string
+		solve func(context equations.FinalReferralTrustEquationContext) error
+	}{
+		{"fixed-point", func(context equations.FinalReferralTrustEquationContext) error {
+			return solver.SolveFinalReferralTrustEquations(context, equations.CreateFinalReferralTrustEquations(dro), opt)
+		}},
+		{"newton", func(context equations.FinalReferralTrustEquationContext) error {
+			_, err := solver.SolveFinalReferralTrustEquationsNewton(context, equations.CreateFinalReferralTrustEquations(dro), opt)
+			return err
+		}},
+		{"blocks", func(context equations.FinalReferralTrustEquationContext) error {
+			_, err := solver.SolveFinalReferralTrustEquationBlocks(context, equations.CreateFinalReferralTrustEquationBlocks(dro), opt)
+			return err
+		}},
+	}
+	for _, tt := range solvers {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.solve(equations.NewDefaultFinalReferralTrustEquationContext(dro)); err != solver.ErrEquationsOptionsNotUsed {
+				t.Errorf("got error %v, want %v", err, solver.ErrEquationsOptionsNotUsed)
+			}
+		})
+	}
+}
+
+func TestSolveFinalReferralTrustEquationsWithContext(t *testing.T) {
+	dro := completeGraph(6)
+	eqs := equations.CreateFinalReferralTrustEquations(dro)
+
+	t.Run("canceled during solving", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		_, err := solver.SolveFinalReferralTrustEquationsWithContext(
+			ctx,
+			equations.NewDefaultFinalReferralTrustEquationContext(dro),
+			eqs,
+			solver.UseOnEpochEndCallback(func(epoch uint, aggregatedDistance float64) error {
+				if epoch == 2 {
+					cancel()
+				}
+				return nil
+			}),
+		)
+
+		var canceledErr *solver.CanceledError
+		if !errors.As(err, &canceledErr) {
+			t.Fatalf("got error %v, want *solver.CanceledError", err)
+		}
+		if canceledErr.Epoch != 3 {
+			t.Errorf("epoch: got %v, want 3", canceledErr.Epoch)
+		}
+		if math.IsInf(canceledErr.Residual, 0) || canceledErr.Residual <= 0 {
+			t.Errorf("residual: got %v, want positive finite number", canceledErr.Residual)
+		}
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v, want context.Canceled", err)
+		}
+	})
+
+	t.Run("canceled before solving", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := solver.SolveFinalReferralTrustEquationsWithContext(
+			ctx,
+			equations.NewConcurrentFinalReferralTrustEquationContext(dro),
+			eqs,
+			solver.UseWorkers(4),
+		)
+
+		var canceledErr *solver.CanceledError
+		if !errors.As(err, &canceledErr) {
+			t.Fatalf("got error %v, want *solver.CanceledError", err)
+		}
+		if canceledErr.Epoch != 0 {
+			t.Errorf("epoch: got %v, want 0", canceledErr.Epoch)
+		}
+	})
+}
+
+func TestSolveFinalReferralTrustEquationsResult(t *testing.T) {
+	tt := solveTests[1]
+	eqs := equations.CreateFinalReferralTrustEquations(tt.dro)
+
+	t.Run("converged", func(t *testing.T) {
+		res, err := solver.SolveFinalReferralTrustEquationsWithResult(
+			equations.NewDefaultFinalReferralTrustEquationContext(tt.dro),
+			eqs,
+			solver.UseTolerance(1e-12),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !res.Converged {
+			t.Errorf("result is not converged: %v", res)
+		}
+		if int(res.Epochs) != len(res.Residuals) {
+			t.Errorf("epochs: got %v, want %v", res.Epochs, len(res.Residuals))
+		}
+		if res.Residual() > 1e-12 {
+			t.Errorf("residual: got %v, want value within tolerance", res.Residual())
+		}
+	})
+
+	t.Run("not converged", func(t *testing.T) {
+		dro := completeGraph(6)
+		res, err := solver.SolveFinalReferralTrustEquationsWithResult(
+			equations.NewDefaultFinalReferralTrustEquationContext(dro),
+			equations.CreateFinalReferralTrustEquations(dro),
+			solver.UseMaxEpochs(2),
+			solver.UseTolerance(1e-12),
+			solver.UseSlowestLinksCount(3),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Converged {
+			t.Errorf("result is converged: %v", res)
+		}
+		if res.Epochs != 2 || len(res.Residuals) != 2 {
+			t.Errorf("epochs: got %v (residuals %v), want 2", res.Epochs, res.Residuals)
+		}
+		if len(res.SlowestLinks) != 3 {
+			t.Fatalf("slowest links: got %v, want 3 links", res.SlowestLinks)
+		}
+		for i := 1; i < len(res.SlowestLinks); i++ {
+			if res.SlowestLinks[i].Distance > res.SlowestLinks[i-1].Distance {
+				t.Errorf("slowest links are not sorted by distance: %v", res.SlowestLinks)
+			}
+		}
+		if res.SlowestLinks[0].Distance != res.Residual() {
+			t.Errorf("largest distance: got %v, want %v", res.SlowestLinks[0].Distance, res.Residual())
+		}
+	})
+}
+
+func TestSolveFinalReferralTrustEquationsWithEquationCallback(t *testing.T) {
+	dro := completeGraph(4)
+	eqs := equations.CreateFinalReferralTrustEquations(dro)
+
+	t.Run("every evaluation is reported", func(t *testing.T) {
+		evaluations := 0
+		res, err := solver.SolveFinalReferralTrustEquationsWithResult(
+			equations.NewDefaultFinalReferralTrustEquationContext(dro),
+			eqs,
+			solver.UseMaxEpochs(3),
+			solver.UseOnEquationEvaluatedCallback(func(link trust.Link, prevValue *opinion.Type, newValue *opinion.Type, distance float64) error {
+				evaluations++
+				want := math.Abs(prevValue.B-newValue.B) + math.Abs(prevValue.D-newValue.D) + math.Abs(prevValue.U-newValue.U)
+				if distance != want {
+					t.Errorf("distance of %v: got %v, want %v", link, distance, want)
+				}
+				return nil
+			}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := int(res.Epochs) * len(dro); evaluations != want {
+			t.Errorf("evaluations: got %v, want %v", evaluations, want)
+		}
+	})
+
+	t.Run("error stops solving", func(t *testing.T) {
+		errStop := errors.New("stop")
+		err := solver.SolveFinalReferralTrustEquations(
+			equations.NewDefaultFinalReferralTrustEquationContext(dro),
+			eqs,
+			solver.UseOnEquationEvaluatedCallback(func(link trust.Link, prevValue *opinion.Type, newValue *opinion.Type, distance float64) error {
+				return errStop
+			}),
+		)
+		if err != errStop {
+			t.Errorf("got error %v, want %v", err, errStop)
+		}
+	})
+}
+
+func TestSolveFinalFunctionalTrustEquations(t *testing.T) {
+	c := uint64(2)
+
+	dro := trust.DirectReferralEvidence{
+		trust.Link{From: 1, To: 2}: evidence.New(2, 2),
+	}.ToDirectReferralOpinion(c)
+	dfo := trust.DirectFunctionalEvidence{
+		trust.Link{From: 1, To: 10}: evidence.New(2, 0),
+		trust.Link{From: 2, To: 10}: evidence.New(0, 2),
+	}.ToDirectFunctionalOpinion(c)
+
+	referralContext := equations.NewDefaultFinalReferralTrustEquationContext(dro)
+	if err := solver.SolveFinalReferralTrustEquations(
+		referralContext,
+		equations.CreateFinalReferralTrustEquations(dro),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	context := equations.NewDefaultFinalFunctionalTrustEquationContext(referralContext, dfo)
+	if err := solver.SolveFinalFunctionalTrustEquations(
+		context,
+		equations.CreateFinalFunctionalTrustEquations(dro, dfo),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	want := trust.FinalFunctionalOpinion{
+		trust.Link{From: 1, To: 10}: opinion.New(0.42857142857142855, 0.14285714285714285, 0.42857142857142855),
+		trust.Link{From: 2, To: 10}: opinion.New(0, 0.5, 0.5),
+	}
+
+	if diff := deep.Equal(context.FinalFunctionalTrust, want); diff != nil {
+		t.Errorf("SolveFinalFunctionalTrustEquations: %v", diff)
+	}
+}
+
+func TestSolveFinalMultinomialFunctionalTrustEquations(t *testing.T) {
+	c := uint64(2)
+
+	dro := trust.DirectReferralEvidence{
+		trust.Link{From: 1, To: 2}: evidence.New(2, 2),
+	}.ToDirectReferralOpinion(c)
+	dfe := trust.DirectMultinomialFunctionalEvidence{
+		trust.Link{From: 1, To: 10}: evidence.NewMultinomial(2, 0),
+		trust.Link{From: 2, To: 10}: evidence.NewMultinomial(0, 2),
+		trust.Link{From: 2, To: 11}: evidence.NewMultinomial(1, 0, 1),
+	}
+	dfo := dfe.ToDirectMultinomialFunctionalOpinion(c)
+
+	referralContext := equations.NewDefaultFinalReferralTrustEquationContext(dro)
+	if err := solver.SolveFinalReferralTrustEquations(
+		referralContext,
+		equations.CreateFinalReferralTrustEquations(dro),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	context := equations.NewDefaultFinalMultinomialFunctionalTrustEquationContext(referralContext, dfo)
+	if err := solver.SolveFinalMultinomialFunctionalTrustEquations(
+		context,
+		equations.CreateFinalFunctionalTrustEquations(dro, dfo),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// binomial opinions are multinomial opinions of two outcomes
+	want := trust.FinalMultinomialFunctionalOpinion{
+		trust.Link{From: 1, To: 10}: opinion.NewMultinomial(0.42857142857142855, 0.42857142857142855, 0.14285714285714285),
+		trust.Link{From: 1, To: 11}: opinion.NewMultinomial(0.75, 0.125, 0, 0.125),
+		trust.Link{From: 2, To: 10}: opinion.NewMultinomial(0.5, 0, 0.5),
+		trust.Link{From: 2, To: 11}: opinion.NewMultinomial(0.5, 0.25, 0, 0.25),
+	}
+
+	if diff := deep.Equal(context.FinalFunctionalTrust, want); diff != nil {
+		t.Errorf("SolveFinalMultinomialFunctionalTrustEquations: %v", diff)
+	}
+
+	// direct functional trust is not modified by evaluation
+	if diff := deep.Equal(dfo, dfe.ToDirectMultinomialFunctionalOpinion(c)); diff != nil {
+		t.Errorf("direct functional trust is modified: %v", diff)
+	}
+}
+
 func BenchmarkSolveFinalReferralTrustEquations(b *testing.B) {
 	for _, nodes := range []uint64{
 		10,
@@ -125,3 +822,51 @@ func BenchmarkSolveFinalReferralTrustEquations(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkSolveFinalReferralTrustEquationsInParallel(b *testing.B) {
+	for _, nodes := range []uint64{
+		10,
+		20,
+		50,
+		100,
+	} {
+		b.Run(fmt.Sprintf("%v nodes", nodes), func(b *testing.B) {
+			dro := make(trust.DirectReferralOpinion, nodes*(nodes-1))
+			for i := uint64(1); i <= nodes; i++ {
+				for j := uint64(1); j <= nodes; j++ {
+					if i != j {
+						dro[trust.Link{From: i, To: j}] = opinion.FromEvidence(2, evidence.New(1, 1))
+					}
+				}
+			}
+			eqs := equations.CreateFinalReferralTrustEquations(dro)
+			context := equations.NewConcurrentFinalReferralTrustEquationContext(dro)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := solver.SolveFinalReferralTrustEquations(
+					context,
+					eqs,
+					solver.UseMaxEpochs(1),
+					solver.UseWorkers(4),
+				); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// completeGraph creates direct referral trust between every pair of nodes
+func completeGraph(nodes uint64) trust.DirectReferralOpinion {
+	dro := make(trust.DirectReferralOpinion, nodes*(nodes-1))
+	for i := uint64(1); i <= nodes; i++ {
+		for j := uint64(1); j <= nodes; j++ {
+			if i != j {
+				dro[trust.Link{From: i, To: j}] = opinion.FromEvidence(2, evidence.New(1, 1))
+			}
+		}
+	}
+	return dro
+}
@@ -0,0 +1,50 @@
+package solver
+
+import (
+	"context"
+	"time"
+
+	"github.com/dimchansky/ebsl-go/trust"
+	"github.com/dimchansky/ebsl-go/trust/equations"
+)
+
+// SolveFinalReferralTrustOfSources creates and solves final referral trust equations of the given source nodes only
+// and returns final referral trust of these sources (rows of R). Only nodes reachable from the sources are involved,
+// so it is much cheaper than solving equations of all source nodes when trust of one or few sources is needed.
+// Equations are created with options set by UseEquationsOptions.
+func SolveFinalReferralTrustOfSources(
+	ctx context.Context,
+	frtContext equations.FinalReferralTrustEquationContext,
+	links trust.IterableLinks,
+	sources []uint64,
+	opts ...Options,
+) (trust.FinalReferralOpinion, *Result, error) {
+	startTime := time.Now()
+
+	solverOpts, err := newOptions(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	eqsOpts := make([]equations.EquationsOption, 0, len(solverOpts.equationsOptions)+1)
+	eqsOpts = append(append(eqsOpts, solverOpts.equationsOptions...), equations.UseSources(sources...))
+
+	var eqs equations.FinalReferralTrustEquations
+	foreachEquation := equations.CreateFinalReferralTrustEquations(links, eqsOpts...).GetFinalReferralTrustEquationIterator()
+	if err := foreachEquation(func(eq *equations.FinalReferralTrustEquation) error {
+		eqs = append(eqs, eq)
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	res, err := solve(ctx, frtContext, eqs, solverOpts, startTime)
+	if err != nil {
+		return nil, res, err
+	}
+
+	fro := make(trust.FinalReferralOpinion, len(eqs))
+	for _, eq := range eqs {
+		fro[eq.R] = frtContext.GetFinalReferralTrust(eq.R)
+	}
+	return fro, res, nil
+}
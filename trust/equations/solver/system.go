@@ -0,0 +1,294 @@
+package solver
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dimchansky/ebsl-go/opinion"
+	"github.com/dimchansky/ebsl-go/trust/equations"
+)
+
+// errCanceled is returned by system when it is done
+var errCanceled = errors.New("solver: canceled")
+
+var inf = math.Inf(1)
+
+// system of final referral trust equations grouped by source node
+type system struct {
+	done       <-chan struct{} // closed when evaluation must be stopped
+	rows       []row
+	activeRows []int          // indexes of rows to evaluate
+	distances  []float64      // distances of the last evaluation of every equation
+	next       []opinion.Type // values evaluated in the current epoch (used in Jacobi update mode only)
+	anderson   *anderson      // history of evaluated values (used by Anderson acceleration only)
+}
+
+// row is a set of equations with the same source node, such equations do not depend on equations of other rows
+type row struct {
+	from   uint64
+	offset int // index of the first equation of the row in the system
+	eqs    []*equations.FinalReferralTrustEquation
+	terms  [][]newtonTerm // consensus terms of every equation (used by Newton method only)
+}
+
+func newSystem(done <-chan struct{}, eqs equations.IterableFinalReferralTrustEquations, opts *options) (*system, error) {
+	var rows []row
+	rowIndex := make(map[uint64]int)
+	count := 0
+
+	foreachEquation := eqs.GetFinalReferralTrustEquationIterator()
+	if err := foreachEquation(func(eq *equations.FinalReferralTrustEquation) error {
+		if isDone(done) {
+			return errCanceled
+		}
+
+		idx, ok := rowIndex[eq.R.From]
+		if !ok {
+			idx = len(rows)
+			rowIndex[eq.R.From] = idx
+			rows = append(rows, row{from: eq.R.From})
+		}
+		rows[idx].eqs = append(rows[idx].eqs, eq)
+		count++
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	offset := 0
+	for i := range rows {
+		rows[i].offset = offset
+		offset += len(rows[i].eqs)
+	}
+
+	activeRows := make([]int, len(rows))
+	for i := range activeRows {
+		activeRows[i] = i
+	}
+
+	// equations that were never evaluated have infinite distance
+	distances := make([]float64, count)
+	for i := range distances {
+		distances[i] = inf
+	}
+
+	var next []opinion.Type
+	if opts.updateMode == JacobiUpdate {
+		next = make([]opinion.Type, count)
+	}
+
+	var acc *anderson
+	if opts.accelerationDepth > 0 {
+		acc = newAnderson(len(rows), count, int(opts.accelerationDepth))
+	}
+
+	if opts.newton {
+		if err := collectTerms(rows); err != nil {
+			return nil, err
+		}
+	}
+
+	return &system{
+		done:       done,
+		rows:       rows,
+		activeRows: activeRows,
+		distances:  distances,
+		next:       next,
+		anderson:   acc,
+	}, nil
+}
+
+// activeEquations returns number of equations in active rows
+func (s *system) activeEquations() (count int) {
+	for _, idx := range s.activeRows {
+		count += len(s.rows[idx].eqs)
+	}
+	return
+}
+
+// aggregateDistances aggregates distances of equations in active rows
+func (s *system) aggregateDistances(distanceAggregator DistanceAggregator) float64 {
+	distanceAggregator.Reset()
+	for _, idx := range s.activeRows {
+		r := &s.rows[idx]
+		for _, dist := range s.distances[r.offset : r.offset+len(r.eqs)] {
+			distanceAggregator.Add(dist)
+		}
+	}
+	return distanceAggregator.Result()
+}
+
+// deactivateConvergedRows removes rows from the active ones if their aggregated distance is within tolerance
+func (s *system) deactivateConvergedRows(distanceAggregator DistanceAggregator, tolerance float64) {
+	activeRows := s.activeRows[:0]
+	for _, idx := range s.activeRows {
+		r := &s.rows[idx]
+
+		distanceAggregator.Reset()
+		for _, dist := range s.distances[r.offset : r.offset+len(r.eqs)] {
+			distanceAggregator.Add(dist)
+		}
+
+		if distanceAggregator.Result() > tolerance {
+			activeRows = append(activeRows, idx)
+		}
+	}
+	s.activeRows = activeRows
+}
+
+// evaluate evaluates all equations of active rows once (makes one Newton step for every active row if Newton method is used)
+func (s *system) evaluate(context equations.FinalReferralTrustEquationContext, opts *options) error {
+	evaluateRow := func(r *row) error { return s.evaluateRow(context, opts, r) }
+	if opts.newton {
+		evaluateRow = func(r *row) error { return s.newtonStep(context, opts, r) }
+	}
+
+	if opts.workers > 1 {
+		return s.forEachActiveRowParallel(int(opts.workers), evaluateRow)
+	}
+	for _, idx := range s.activeRows {
+		if err := evaluateRow(&s.rows[idx]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEachActiveRowParallel calls function for every active row, rows are handled by several workers in parallel
+func (s *system) forEachActiveRowParallel(workers int, f func(r *row) error) error {
+	activeRows := s.activeRows
+	if workers > len(activeRows) {
+		workers = len(activeRows)
+	}
+
+	var (
+		nextRow  int64 = -1
+		failed   int32
+		errOnce  sync.Once
+		firstErr error
+		wg       sync.WaitGroup
+	)
+
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&failed) == 0 {
+				idx := int(atomic.AddInt64(&nextRow, 1))
+				if idx >= len(activeRows) {
+					return
+				}
+				if err := f(&s.rows[activeRows[idx]]); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						atomic.StoreInt32(&failed, 1)
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+func (s *system) evaluateRow(context equations.FinalReferralTrustEquationContext, opts *options, r *row) error {
+	distanceFun := opts.distanceFun
+	onEquationEvaluated := opts.onEquationEvaluated
+	distances := s.distances[r.offset : r.offset+len(r.eqs)]
+
+	for i, eq := range r.eqs {
+		if isDone(s.done) {
+			return errCanceled
+		}
+
+		prevValue := context.GetFinalReferralTrust(eq.R)
+		newValue, err := s.evaluateEquation(context, eq, r.offset+i)
+		if err != nil {
+			return err
+		}
+		dist := distanceFun(&prevValue, newValue)
+		distances[i] = dist
+
+		if s.anderson != nil {
+			s.anderson.record(r.offset+i, &prevValue, newValue)
+		}
+
+		if onEquationEvaluated != nil {
+			if err := onEquationEvaluated(eq.R, &prevValue, newValue, dist); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isDone(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// evaluateEquation evaluates equation with index `idx` and either updates context with the new value
+// or saves it to be applied at the end of epoch (in Jacobi update mode)
+func (s *system) evaluateEquation(context equations.FinalReferralTrustEquationContext, eq *equations.FinalReferralTrustEquation, idx int) (*opinion.Type, error) {
+	if s.next == nil {
+		return eq.EvaluateFinalReferralTrust(context)
+	}
+
+	newValue, err := equations.EvaluateFinalReferralTrustExpression(context, eq.Expression)
+	if err != nil {
+		return nil, err
+	}
+	s.next[idx] = *newValue
+	return &s.next[idx], nil
+}
+
+// applyNextValues updates context with values evaluated in the current epoch (in Jacobi update mode)
+func (s *system) applyNextValues(context equations.FinalReferralTrustEquationContext) {
+	for _, idx := range s.activeRows {
+		r := &s.rows[idx]
+		for i, eq := range r.eqs {
+			context.SetFinalReferralTrust(eq.R, &s.next[r.offset+i])
+		}
+	}
+}
+
+// slowestLinks returns at most `count` links of the equations with the largest distance
+func (s *system) slowestLinks(count int) []LinkDistance {
+	if count <= 0 {
+		return nil
+	}
+
+	res := make([]LinkDistance, 0, count+1)
+	for _, r := range s.rows {
+		for i, eq := range r.eqs {
+			res = insertLinkDistance(res, LinkDistance{Link: eq.R, Distance: s.distances[r.offset+i]}, count)
+		}
+	}
+	return res
+}
+
+// insertLinkDistance inserts link distance to the list sorted by distance descending if it is one of the `count` largest
+func insertLinkDistance(res []LinkDistance, ld LinkDistance, count int) []LinkDistance {
+	dist := ld.Distance
+	if len(res) == count && res[count-1].Distance >= dist {
+		return res
+	}
+
+	pos := sort.Search(len(res), func(j int) bool { return res[j].Distance < dist })
+	res = append(res, LinkDistance{})
+	copy(res[pos+1:], res[pos:])
+	res[pos] = ld
+	if len(res) > count {
+		res = res[:count]
+	}
+	return res
+}
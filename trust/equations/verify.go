@@ -0,0 +1,144 @@
+package equations
+
+import (
+	"math"
+	"sort"
+
+	"github.com/dimchansky/ebsl-go/opinion"
+	"github.com/dimchansky/ebsl-go/trust"
+)
+
+// Verification is a result of verification of final referral trust equations
+type Verification struct {
+	// Equations is the number of verified equations
+	Equations int
+	// MaxResidual is the largest residual of the equations (0 if there are no equations)
+	MaxResidual float64
+	// WorstLinks are links of the equations with the largest residual, sorted by residual descending
+	WorstLinks []LinkResidual
+}
+
+// LinkResidual is a residual of the final referral trust equation
+type LinkResidual struct {
+	Link trust.Link
+	// Value is the final referral trust value of the context
+	Value opinion.Type
+	// Evaluated is the value of the equation expression
+	Evaluated opinion.Type
+	// Residual is the distance between the value and the evaluated value
+	Residual float64
+}
+
+// ResidualFun returns distance between final referral trust value of the link and its evaluated value
+type ResidualFun func(link trust.Link, value, evaluated *opinion.Type) float64
+
+// VerifyOption configures verification of equations
+type VerifyOption func(opts *verifyOptions)
+
+type verifyOptions struct {
+	worstLinks int
+	residual   ResidualFun
+}
+
+// UseWorstLinks sets the number of links with the largest residual to report (10 by default)
+func UseWorstLinks(count int) VerifyOption {
+	return func(opts *verifyOptions) {
+		opts.worstLinks = count
+	}
+}
+
+// UseResidualFunction sets residual function (ChebyshevResidual is used by default)
+func UseResidualFunction(residual ResidualFun) VerifyOption {
+	return func(opts *verifyOptions) {
+		opts.residual = residual
+	}
+}
+
+// ChebyshevResidual returns the largest absolute difference of the opinion components
+func ChebyshevResidual(_ trust.Link, value, evaluated *opinion.Type) float64 {
+	return math.Max(math.Abs(value.B-evaluated.B), math.Max(math.Abs(value.D-evaluated.D), math.Abs(value.U-evaluated.U)))
+}
+
+func newVerifyOptions(opts []VerifyOption) *verifyOptions {
+	verifyOpts := &verifyOptions{
+		worstLinks: 10,
+		residual:   ChebyshevResidual,
+	}
+	for _, applyOption := range opts {
+		applyOption(verifyOpts)
+	}
+	return verifyOpts
+}
+
+// Verify evaluates expression of every equation and compares it with the final referral trust value of the context,
+// so it checks that values of the context satisfy R = expression(R, A). Final referral trust of the equations is
+// read once before evaluation, so every equation is evaluated against the same snapshot of values even if context
+// is updated meanwhile, other values of the context (direct referral trust, discount) must not change.
+// Verification does not change anything.
+func Verify(context FinalReferralTrustExpressionContext, eqs IterableFinalReferralTrustEquations, opts ...VerifyOption) (*Verification, error) {
+	verifyOpts := newVerifyOptions(opts)
+
+	var equations []*FinalReferralTrustEquation
+	snapshot := snapshotContext{FinalReferralTrustExpressionContext: context, values: make(map[trust.Link]opinion.Type)}
+	foreachEquation := eqs.GetFinalReferralTrustEquationIterator()
+	if err := foreachEquation(func(eq *FinalReferralTrustEquation) error {
+		equations = append(equations, eq)
+		snapshot.values[eq.R] = context.GetFinalReferralTrust(eq.R)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	res := &Verification{}
+	for _, eq := range equations {
+		evaluated, err := EvaluateFinalReferralTrustExpression(snapshot, eq.Expression)
+		if err != nil {
+			return nil, err
+		}
+		value := snapshot.values[eq.R]
+		residual := verifyOpts.residual(eq.R, &value, evaluated)
+
+		res.Equations++
+		if residual > res.MaxResidual {
+			res.MaxResidual = residual
+		}
+		res.WorstLinks = insertLinkResidual(res.WorstLinks, LinkResidual{
+			Link:      eq.R,
+			Value:     value,
+			Evaluated: *evaluated,
+			Residual:  residual,
+		}, verifyOpts.worstLinks)
+	}
+	return res, nil
+}
+
+// snapshotContext returns the saved final referral trust values of the equations,
+// the rest is read from the underlying context
+type snapshotContext struct {
+	FinalReferralTrustExpressionContext
+	values map[trust.Link]opinion.Type
+}
+
+func (c snapshotContext) GetFinalReferralTrust(link trust.Link) opinion.Type {
+	if value, ok := c.values[link]; ok {
+		return value
+	}
+	return c.FinalReferralTrustExpressionContext.GetFinalReferralTrust(link)
+}
+
+// insertLinkResidual inserts link residual to the list sorted by residual descending keeping at most `count` links
+func insertLinkResidual(res []LinkResidual, lr LinkResidual, count int) []LinkResidual {
+	residual := lr.Residual
+	if count <= 0 || len(res) == count && res[count-1].Residual >= residual {
+		return res
+	}
+
+	pos := sort.Search(len(res), func(j int) bool { return res[j].Residual < residual })
+	res = append(res, LinkResidual{})
+	copy(res[pos+1:], res[pos:])
+	res[pos] = lr
+	if len(res) > count {
+		res = res[:count]
+	}
+	return res
+}
@@ -0,0 +1,108 @@
+package equations_test
+
+import (
+	"testing"
+
+	"github.com/dimchansky/ebsl-go/evidence"
+	"github.com/dimchansky/ebsl-go/opinion"
+	"github.com/dimchansky/ebsl-go/trust"
+	"github.com/dimchansky/ebsl-go/trust/equations"
+	"github.com/go-test/deep"
+)
+
+func TestVerify(t *testing.T) {
+	dro := trust.DirectReferralEvidence{
+		trust.Link{From: 1, To: 2}: evidence.New(4, 0),
+		trust.Link{From: 2, To: 3}: evidence.New(2, 2),
+		trust.Link{From: 1, To: 3}: evidence.New(0, 2),
+	}.ToDirectReferralOpinion(2)
+	eqs := equations.CreateFinalReferralTrustEquations(dro, equations.UseSortedOrder())
+
+	// graph is acyclic, so single evaluation of the equations in topological order solves them
+	context := equations.NewDefaultFinalReferralTrustEquationContext(dro)
+	foreachBlock := equations.CreateFinalReferralTrustEquationBlocks(dro, equations.UseSortedOrder()).GetFinalReferralTrustEquationBlockIterator()
+	if err := foreachBlock(func(block *equations.FinalReferralTrustEquationBlock) error {
+		for _, eq := range block.Equations {
+			if _, err := eq.EvaluateFinalReferralTrust(context); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := equations.Verify(context, eqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Equations != 3 || res.MaxResidual != 0 {
+		t.Errorf("got %v equations with max residual %v, want 3 equations with zero residual", res.Equations, res.MaxResidual)
+	}
+
+	// break the solution
+	solution := make(trust.FinalReferralOpinion, len(context.FinalReferralTrust))
+	for link, value := range context.FinalReferralTrust {
+		solution[link] = value
+	}
+	broken := opinion.New(0.5, 0, 0.5)
+	context.FinalReferralTrust[trust.Link{From: 1, To: 2}] = broken
+
+	res, err = equations.Verify(context, eqs, equations.UseWorstLinks(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []equations.LinkResidual{{
+		Link:      trust.Link{From: 1, To: 2},
+		Value:     broken,
+		Evaluated: solution[trust.Link{From: 1, To: 2}],
+		Residual:  equations.ChebyshevResidual(trust.Link{From: 1, To: 2}, &broken, &opinion.Type{B: 2.0 / 3, U: 1.0 / 3}),
+	}}
+	if diff := deep.Equal(res.WorstLinks, want); diff != nil {
+		t.Errorf("worst links: %v", diff)
+	}
+	if res.MaxResidual != res.WorstLinks[0].Residual {
+		t.Errorf("max residual %v is not the residual of the worst link %v", res.MaxResidual, res.WorstLinks[0].Residual)
+	}
+
+	// verification does not change the context
+	if got := context.FinalReferralTrust[trust.Link{From: 1, To: 2}]; got != broken {
+		t.Errorf("context is changed: %v", got)
+	}
+}
+
+// changingContext changes final referral trust of the link every time it is read, as solver updating it does
+type changingContext struct {
+	*equations.DefaultFinalReferralTrustEquationContext
+}
+
+func (c changingContext) GetFinalReferralTrust(link trust.Link) opinion.Type {
+	value := c.DefaultFinalReferralTrustEquationContext.GetFinalReferralTrust(link)
+	if link.From != link.To {
+		c.FinalReferralTrust[link] = opinion.New(value.B/2, value.D, 1-value.B/2-value.D)
+	}
+	return value
+}
+
+func TestVerifyUsesSnapshotOfContext(t *testing.T) {
+	dro := trust.DirectReferralEvidence{
+		trust.Link{From: 1, To: 2}: evidence.New(2, 2),
+		trust.Link{From: 2, To: 3}: evidence.New(2, 2),
+		trust.Link{From: 3, To: 2}: evidence.New(2, 2),
+	}.ToDirectReferralOpinion(2)
+	eqs := equations.CreateFinalReferralTrustEquations(dro, equations.UseSortedOrder())
+
+	snapshot := equations.NewDefaultFinalReferralTrustEquationContext(dro)
+	want, err := equations.Verify(snapshot, eqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := equations.Verify(changingContext{equations.NewDefaultFinalReferralTrustEquationContext(dro)}, eqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("Verify: %v", diff)
+	}
+}
@@ -0,0 +1,569 @@
+// Package evidenceio reads direct trust evidence records from text formats:
+// whitespace separated fields, CSV, TSV (with optional headers) and JSON Lines.
+// Records can have optional timestamp: Unix time in seconds, RFC 3339 time or date (YYYY-MM-DD).
+package evidenceio
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dimchansky/ebsl-go/evidence"
+	"github.com/dimchansky/ebsl-go/trust"
+)
+
+// Format of evidence records
+type Format int
+
+const (
+	// AutoFormat detects format by file extension or by the first record
+	AutoFormat Format = iota
+	// WhitespaceFormat has fields separated by whitespace: `from to p n [time]`
+	WhitespaceFormat
+	// CSVFormat has comma separated fields
+	CSVFormat
+	// TSVFormat has tab separated fields
+	TSVFormat
+	// JSONLinesFormat has JSON object on every line: `{"from": .., "to": .., "p": .., "n": .., "time": ..}`
+	JSONLinesFormat
+)
+
+var formatNames = [...]string{"auto", "whitespace", "csv", "tsv", "jsonl"}
+
+// String implements fmt.Stringer
+func (f Format) String() string {
+	if f < 0 || int(f) >= len(formatNames) {
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+	return formatNames[f]
+}
+
+// ParseFormat parses name of the format: auto, whitespace, csv, tsv or jsonl
+func ParseFormat(s string) (Format, error) {
+	for f, name := range formatNames {
+		if s == name {
+			return Format(f), nil
+		}
+	}
+	return AutoFormat, fmt.Errorf("evidenceio: unknown format: %v", s)
+}
+
+// Columns are names of the record fields (columns of the header or keys of JSON object), Time column is optional
+type Columns struct {
+	From, To, Positive, Negative, Time string
+}
+
+// DefaultColumns are names of the fields used by default
+var DefaultColumns = Columns{From: "from", To: "to", Positive: "p", Negative: "n", Time: "time"}
+
+// NodeParser converts node field of the record to node identifier
+type NodeParser func(s string) (uint64, error)
+
+// IntegerNodes parses nodes as non-negative integers
+func IntegerNodes(s string) (uint64, error) {
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// LabeledNodes treats nodes as arbitrary string labels registered in the node registry
+func LabeledNodes(registry *trust.NodeRegistry) NodeParser {
+	return func(s string) (uint64, error) {
+		return registry.ID(s), nil
+	}
+}
+
+// ParseError is an error of parsing record of the given line
+type ParseError struct {
+	// File is the name of the file (empty if records are not read from file)
+	File string
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("%v:%d: %v", e.File, e.Line, e.Err)
+}
+
+// Unwrap returns the reason of the error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// InvalidRecordHandler handles error of the invalid record: record is skipped if handler returns nil,
+// otherwise parsing stops with the returned error
+type InvalidRecordHandler func(err *ParseError) error
+
+// Option configures reading of evidence records
+type Option func(opts *options)
+
+type headerMode int
+
+const (
+	detectHeader headerMode = iota
+	withHeader
+	withoutHeader
+)
+
+type options struct {
+	format        Format
+	nodes         NodeParser
+	columns       Columns
+	indexes       [4]int // indexes of from, to, p and n fields of records without header
+	timeIndex     *int   // index of the optional time field of records without header (negative if there is no time field)
+	header        headerMode
+	commentPrefix string
+	fileName      string
+	onInvalid     InvalidRecordHandler
+}
+
+// UseFormat sets format of the records (AutoFormat is used by default)
+func UseFormat(format Format) Option {
+	return func(opts *options) {
+		opts.format = format
+	}
+}
+
+// UseNodeParser sets parser of the nodes (IntegerNodes is used by default)
+func UseNodeParser(nodes NodeParser) Option {
+	return func(opts *options) {
+		opts.nodes = nodes
+	}
+}
+
+// UseColumns sets names of the header columns and keys of JSON objects (DefaultColumns are used by default)
+func UseColumns(columns Columns) Option {
+	return func(opts *options) {
+		opts.columns = columns
+	}
+}
+
+// UseColumnIndexes sets zero-based indexes of the fields of records without header (0, 1, 2, 3 by default)
+func UseColumnIndexes(from, to, positive, negative int) Option {
+	return func(opts *options) {
+		opts.indexes = [4]int{from, to, positive, negative}
+	}
+}
+
+// UseTimeColumnIndex sets zero-based index of the optional time field of records without header
+// (the field following the last evidence field by default, negative index means records have no time field)
+func UseTimeColumnIndex(index int) Option {
+	return func(opts *options) {
+		opts.timeIndex = &index
+	}
+}
+
+// UseHeader sets whether the first record is a header. By default the first record is a header
+// if its evidence fields are not numbers and it has some of the column names (see UseColumns).
+func UseHeader(header bool) Option {
+	return func(opts *options) {
+		if header {
+			opts.header = withHeader
+		} else {
+			opts.header = withoutHeader
+		}
+	}
+}
+
+// UseCommentPrefix sets prefix of the comment lines ("#" by default), empty prefix disables comments
+func UseCommentPrefix(prefix string) Option {
+	return func(opts *options) {
+		opts.commentPrefix = prefix
+	}
+}
+
+// UseInvalidRecordHandler sets handler of the invalid records, so they can be skipped or collected.
+// Invalid header is not handled, parsing stops at it. By default parsing stops at the first invalid record.
+func UseInvalidRecordHandler(onInvalid InvalidRecordHandler) Option {
+	return func(opts *options) {
+		opts.onInvalid = onInvalid
+	}
+}
+
+// UseFileName sets file name reported by parse errors
+func UseFileName(fileName string) Option {
+	return func(opts *options) {
+		opts.fileName = fileName
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		nodes:         IntegerNodes,
+		columns:       DefaultColumns,
+		indexes:       [4]int{0, 1, 2, 3},
+		commentPrefix: "#",
+		onInvalid:     func(err *ParseError) error { return err },
+	}
+	for _, applyOption := range opts {
+		applyOption(o)
+	}
+	if o.timeIndex == nil {
+		timeIndex := maxIndex(o.indexes) + 1
+		o.timeIndex = &timeIndex
+	}
+	return o
+}
+
+// File is evidence records file, file is read every time evidences are iterated
+type File struct {
+	fileName string
+	opts     []Option
+}
+
+// NewFile creates IterableEvidences of the file, format is detected by file extension
+// (.csv, .tsv, .jsonl, .ndjson) or by the first record unless it is set by option.
+func NewFile(fileName string, opts ...Option) *File {
+	return &File{fileName: fileName, opts: opts}
+}
+
+// GetEvidenceIterator implements trust.IterableEvidences interface, timestamps of the records are ignored
+func (f *File) GetEvidenceIterator() trust.EvidenceIterator {
+	return func(onNext trust.NextEvidenceHandler) error {
+		return f.GetTimedEvidenceIterator()(ignoreTime(onNext))
+	}
+}
+
+// GetTimedEvidenceIterator implements trust.IterableTimedEvidences interface
+func (f *File) GetTimedEvidenceIterator() trust.TimedEvidenceIterator {
+	return func(onNext trust.NextTimedEvidenceHandler) (err error) {
+		inputFile, err := os.Open(f.fileName)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if tErr := inputFile.Close(); tErr != nil && err == nil {
+				err = tErr
+			}
+		}()
+
+		opts := []Option{UseFileName(f.fileName)}
+		if format := formatOfFileName(f.fileName); format != AutoFormat {
+			opts = append(opts, UseFormat(format))
+		}
+		opts = append(opts, f.opts...)
+		return ParseTimed(inputFile, onNext, opts...)
+	}
+}
+
+func formatOfFileName(fileName string) Format {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return CSVFormat
+	case ".tsv":
+		return TSVFormat
+	case ".jsonl", ".ndjson":
+		return JSONLinesFormat
+	default:
+		return AutoFormat
+	}
+}
+
+// Parse reads evidence records and calls `onNext` for every record, timestamps of the records are ignored (see ParseTimed)
+func Parse(r io.Reader, onNext trust.NextEvidenceHandler, opts ...Option) error {
+	return ParseTimed(r, ignoreTime(onNext), opts...)
+}
+
+func ignoreTime(onNext trust.NextEvidenceHandler) trust.NextTimedEvidenceHandler {
+	return func(link trust.Link, ev evidence.Type, _ time.Time) error {
+		return onNext(link, ev)
+	}
+}
+
+// ParseTimed reads evidence records and calls `onNext` for every record with its timestamp (zero if record has no time).
+// Empty lines and comment lines are skipped. Record is invalid if it cannot be parsed, its evidence is negative,
+// NaN or infinite or it is a self-loop. Invalid records are handled by invalid record handler
+// (parsing stops at the first one with ParseError by default).
+// Errors returned by `onNext` are returned as is, except trust.DuplicateEvidenceError (see trust.RejectDuplicateEvidence)
+// which is returned as ParseError of the duplicate record.
+func ParseTimed(r io.Reader, onNext trust.NextTimedEvidenceHandler, opts ...Option) error {
+	p := &parser{options: newOptions(opts)}
+
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := sc.Text()
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff") // byte order mark
+		}
+		if trimmed := strings.TrimSpace(text); trimmed == "" ||
+			p.commentPrefix != "" && strings.HasPrefix(trimmed, p.commentPrefix) {
+			continue
+		}
+
+		link, ev, timestamp, ok, err := p.parseRecord(text)
+		if err != nil {
+			parseErr := &ParseError{File: p.fileName, Line: line, Err: err}
+			if !ok { // invalid header
+				return parseErr
+			}
+			if err := p.onInvalid(parseErr); err != nil {
+				return err
+			}
+			continue
+		}
+		if !ok { // header
+			continue
+		}
+		if err := onNext(link, ev, timestamp); err != nil {
+			var duplicateErr *trust.DuplicateEvidenceError
+			if errors.As(err, &duplicateErr) {
+				return &ParseError{File: p.fileName, Line: line, Err: err}
+			}
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+type parser struct {
+	*options
+	records int // number of parsed records including header
+}
+
+// parseRecord parses line of the record, it returns false if the record is a header
+// (error of the record is returned with true)
+func (p *parser) parseRecord(text string) (link trust.Link, ev evidence.Type, timestamp time.Time, ok bool, err error) {
+	if p.format == AutoFormat {
+		p.format = detectFormat(text)
+	}
+	p.records++
+
+	var fields [5]string
+	if p.format == JSONLinesFormat {
+		fields, err = p.jsonFields(text)
+	} else {
+		var record []string
+		if record, err = p.split(text); err != nil {
+			return link, ev, timestamp, true, err
+		}
+		if p.records == 1 && p.isHeader(record) {
+			err = p.readHeader(record)
+			return
+		}
+		fields, err = p.recordFields(record)
+	}
+	ok = true
+	if err != nil {
+		return
+	}
+
+	if link.From, err = p.parseNode(fields[0], p.columns.From); err != nil {
+		return
+	}
+	if link.To, err = p.parseNode(fields[1], p.columns.To); err != nil {
+		return
+	}
+	if link.From == link.To {
+		err = fmt.Errorf("self-loop of node %q", fields[0])
+		return
+	}
+	if ev.P, err = parseEvidence(fields[2], p.columns.Positive); err != nil {
+		return
+	}
+	if ev.N, err = parseEvidence(fields[3], p.columns.Negative); err != nil {
+		return
+	}
+	if timestamp, err = parseTime(fields[4]); err != nil {
+		return
+	}
+	return link, ev, timestamp, true, nil
+}
+
+// detectFormat detects format by the first record
+func detectFormat(text string) Format {
+	trimmed := strings.TrimSpace(text)
+	switch {
+	case strings.HasPrefix(trimmed, "{"):
+		return JSONLinesFormat
+	case strings.ContainsRune(trimmed, '\t'):
+		return TSVFormat
+	case strings.ContainsRune(trimmed, ','):
+		return CSVFormat
+	default:
+		return WhitespaceFormat
+	}
+}
+
+func (p *parser) split(text string) ([]string, error) {
+	var comma rune
+	switch p.format {
+	case CSVFormat:
+		comma = ','
+	case TSVFormat:
+		comma = '\t'
+	default:
+		return strings.Fields(text), nil
+	}
+
+	r := csv.NewReader(strings.NewReader(text))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = comma != '\t' // leading tab is an empty field of TSV
+	record, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i := range record {
+		record[i] = strings.TrimSpace(record[i])
+	}
+	return record, nil
+}
+
+// isHeader checks if the first record is a header: unless header is required or forbidden by option,
+// it is a header if all its evidence fields are not numbers or some of them are not numbers and it has some
+// of the column names, otherwise it is an ordinary (possibly invalid) record.
+// So header with unexpected column names is reported as invalid header rather than as invalid record.
+func (p *parser) isHeader(record []string) bool {
+	switch p.header {
+	case withHeader:
+		return true
+	case withoutHeader:
+		return false
+	}
+	numbers, texts := 0, 0
+	for _, i := range p.indexes[2:] {
+		if i >= len(record) {
+			continue
+		}
+		if _, err := strconv.ParseFloat(record[i], 64); err == nil {
+			numbers++
+		} else {
+			texts++
+		}
+	}
+	switch len(p.indexes[2:]) {
+	case texts:
+		return true
+	case numbers:
+		return false
+	}
+	for _, column := range record {
+		for _, name := range [...]string{p.columns.From, p.columns.To, p.columns.Positive, p.columns.Negative, p.columns.Time} {
+			if strings.EqualFold(column, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readHeader finds indexes of the columns in the header
+func (p *parser) readHeader(record []string) error {
+	for i, name := range [...]string{p.columns.From, p.columns.To, p.columns.Positive, p.columns.Negative} {
+		index := -1
+		for j, column := range record {
+			if strings.EqualFold(column, name) {
+				index = j
+				break
+			}
+		}
+		if index < 0 {
+			return fmt.Errorf("header has no column %q", name)
+		}
+		p.indexes[i] = index
+	}
+
+	timeIndex := -1
+	for j, column := range record {
+		if strings.EqualFold(column, p.columns.Time) {
+			timeIndex = j
+			break
+		}
+	}
+	p.timeIndex = &timeIndex
+	return nil
+}
+
+func (p *parser) recordFields(record []string) (fields [5]string, err error) {
+	for i, index := range p.indexes {
+		if index < 0 || index >= len(record) {
+			return fields, fmt.Errorf("expected at least %d fields, got %d", maxIndex(p.indexes)+1, len(record))
+		}
+		fields[i] = record[index]
+	}
+	if timeIndex := *p.timeIndex; timeIndex >= 0 && timeIndex < len(record) {
+		fields[4] = record[timeIndex]
+	}
+	return fields, nil
+}
+
+func maxIndex(indexes [4]int) int {
+	res := indexes[0]
+	for _, i := range indexes[1:] {
+		if i > res {
+			res = i
+		}
+	}
+	return res
+}
+
+func (p *parser) jsonFields(text string) (fields [5]string, err error) {
+	var object map[string]json.RawMessage
+	if err = json.Unmarshal([]byte(text), &object); err != nil {
+		return
+	}
+	for i, name := range [...]string{p.columns.From, p.columns.To, p.columns.Positive, p.columns.Negative, p.columns.Time} {
+		value, ok := object[name]
+		if !ok {
+			if name == p.columns.Time { // time is optional
+				continue
+			}
+			return fields, fmt.Errorf("object has no key %q", name)
+		}
+		var s string
+		if json.Unmarshal(value, &s) == nil { // node labels are strings, numbers are used as is
+			fields[i] = s
+		} else {
+			fields[i] = string(value)
+		}
+	}
+	return fields, nil
+}
+
+func (p *parser) parseNode(s string, name string) (uint64, error) {
+	node, err := p.nodes(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %v node %q: %v", name, s, err)
+	}
+	return node, nil
+}
+
+func parseEvidence(s string, name string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %v evidence %q: %v", name, s, err)
+	}
+	if !(v >= 0) || math.IsInf(v, 1) {
+		return 0, fmt.Errorf("%v evidence must be non-negative finite number: %v", name, s)
+	}
+	return v, nil
+}
+
+// parseTime parses Unix time in seconds, RFC 3339 time or date, empty string is zero time
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil && !math.IsNaN(seconds) && !math.IsInf(seconds, 0) {
+		sec, frac := math.Modf(seconds)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: expected Unix time in seconds, RFC 3339 time or date", s)
+}
@@ -0,0 +1,272 @@
+package evidenceio_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimchansky/ebsl-go/evidence"
+	"github.com/dimchansky/ebsl-go/trust"
+	"github.com/dimchansky/ebsl-go/trust/evidenceio"
+	"github.com/go-test/deep"
+)
+
+type record struct {
+	link trust.Link
+	ev   evidence.Type
+}
+
+func parse(input string, opts ...evidenceio.Option) ([]record, error) {
+	var res []record
+	err := evidenceio.Parse(strings.NewReader(input), func(link trust.Link, ev evidence.Type) error {
+		res = append(res, record{link, ev})
+		return nil
+	}, opts...)
+	return res, err
+}
+
+func TestParse(t *testing.T) {
+	want := []record{
+		{trust.Link{From: 1, To: 2}, evidence.New(3, 0.5)},
+		{trust.Link{From: 2, To: 1}, evidence.New(0, 7)},
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		opts  []evidenceio.Option
+	}{
+		{"whitespace", "1 2 3 0.5\n2\t1  0 7\n", nil},
+		{"whitespace with comments", "# from to p n\n\n1 2 3 0.5\n  # comment\n2 1 0 7\n", nil},
+		{"csv", "1,2,3,0.5\n2, 1, 0, 7\n", nil},
+		{"csv with header", "\ufefffrom,to,p,n\n1,2,3,0.5\n2,1,0,7\n", nil},
+		{"csv with quoted fields", "\"1\",\"2\",3,0.5\n2,1,0,\"7\"\n", nil},
+		{"csv with columns", "n,source,p,target\n0.5,1,3,2\n7,2,0,1\n",
+			[]evidenceio.Option{evidenceio.UseColumns(evidenceio.Columns{From: "source", To: "target", Positive: "p", Negative: "n"})}},
+		{"csv with column indexes", "x,3,0.5,1,2\ny,0,7,2,1\n", []evidenceio.Option{evidenceio.UseColumnIndexes(3, 4, 1, 2)}},
+		{"tsv with header", "From\tTo\tP\tN\n1\t2\t3\t0.5\n2\t1\t0\t7\n", nil},
+		{"jsonl", "{\"from\":1,\"to\":2,\"p\":3,\"n\":0.5}\n{\"n\":7,\"p\":0,\"to\":\"1\",\"from\":\"2\"}\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parse(tt.input, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := deep.Equal(got, want); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestParseLabeledNodes(t *testing.T) {
+	registry := trust.NewNodeRegistry()
+	got, err := parse("{\"from\":\"alice@example.com\",\"to\":\"did:example:1\",\"p\":1,\"n\":0}\n",
+		evidenceio.UseNodeParser(evidenceio.LabeledNodes(registry)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []record{{registry.Link("alice@example.com", "did:example:1"), evidence.New(1, 0)}}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Error(diff)
+	}
+
+	// commas do not separate fields of whitespace format, so the first record is not detected as CSV
+	got, err = parse("Doe, John\tbob 2 1\n",
+		evidenceio.UseFormat(evidenceio.WhitespaceFormat), evidenceio.UseColumnIndexes(0, 2, 3, 4),
+		evidenceio.UseNodeParser(evidenceio.LabeledNodes(registry)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []record{{registry.Link("Doe,", "bob"), evidence.New(2, 1)}}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestParseTimed(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  []evidenceio.Option
+	}{
+		{"whitespace", "1 2 3 0.5 2020-01-02T03:04:05Z\n2 1 0 7\n", nil},
+		{"csv with header", "time,from,to,p,n\n1577934245,1,2,3,0.5\n,2,1,0,7\n", nil},
+		{"csv without time column", "from,to,p,n,time\n1,2,3,0.5,2020-01-02T04:04:05+01:00\n2,1,0,7\n", nil},
+		{"tsv with column indexes", "2020-01-02T03:04:05Z\t1\t2\t3\t0.5\n\t2\t1\t0\t7\n",
+			[]evidenceio.Option{evidenceio.UseColumnIndexes(1, 2, 3, 4), evidenceio.UseTimeColumnIndex(0)}},
+		{"jsonl", "{\"from\":1,\"to\":2,\"p\":3,\"n\":0.5,\"time\":1577934245}\n{\"from\":2,\"to\":1,\"p\":0,\"n\":7}\n", nil},
+	}
+	want := []time.Time{time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), {}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []time.Time
+			if err := evidenceio.ParseTimed(strings.NewReader(tt.input), func(link trust.Link, ev evidence.Type, timestamp time.Time) error {
+				got = append(got, timestamp)
+				return nil
+			}, tt.opts...); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("got %v records, want %v", len(got), len(want))
+			}
+			for i := range want {
+				if !got[i].Equal(want[i]) {
+					t.Errorf("record %v: got time %v, want %v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+
+	if _, err := parse("1 2 3 0.5 yesterday\n"); err == nil {
+		t.Error("expected error of invalid time")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  []evidenceio.Option
+		line  int
+	}{
+		{"invalid evidence", "1 2 3 0\n1 3 x 0\n", nil, 2},
+		{"negative evidence", "1 2 3 -1\n", nil, 1},
+		{"NaN evidence", "1 2 NaN 1\n", nil, 1},
+		{"infinite evidence", "1 2 3 +Inf\n", nil, 1},
+		{"self-loop", "1 2 3 1\n2 2 3 1\n", nil, 2},
+		{"negative node", "# comment\n-1 2 3 1\n", nil, 2},
+		{"missing fields", "1,2,3,4\n1,2,3\n", nil, 2},
+		{"missing column", "from,to,positive,negative\n1,2,3,4\n", nil, 1},
+		{"header is not allowed", "from,to,p,n\n", []evidenceio.Option{evidenceio.UseHeader(false)}, 1},
+		{"missing key", "{\"from\":1,\"to\":2,\"p\":3}\n", nil, 1},
+		{"invalid json", "{\"from\":1,\n", nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse(tt.input, tt.opts...)
+			var parseErr *evidenceio.ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("got error %v, want parse error", err)
+			}
+			if parseErr.Line != tt.line {
+				t.Errorf("got error of line %v, want %v: %v", parseErr.Line, tt.line, err)
+			}
+		})
+	}
+}
+
+func TestParseWithInvalidRecordHandler(t *testing.T) {
+	var lines []int
+	got, err := parse("1 2 3 1\n1 3 x 0\n2 2 3 1\n2 1 1 1\n",
+		evidenceio.UseFileName("evidence.txt"),
+		evidenceio.UseInvalidRecordHandler(func(err *evidenceio.ParseError) error {
+			if err.File != "evidence.txt" {
+				t.Errorf("got file %q, want %q", err.File, "evidence.txt")
+			}
+			lines = append(lines, err.Line)
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []record{
+		{trust.Link{From: 1, To: 2}, evidence.New(3, 1)},
+		{trust.Link{From: 2, To: 1}, evidence.New(1, 1)},
+	}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(lines, []int{2, 3}); diff != nil {
+		t.Errorf("lines of invalid records: %v", diff)
+	}
+
+	// malformed first record is not a header
+	for _, input := range []string{"1 2 x 0\n2 1 1 1\n", "1 2 3\n2 1 1 1\n", "1,2,x,0\n2,1,1,1\n"} {
+		lines = nil
+		got, err = parse(input, evidenceio.UseInvalidRecordHandler(func(err *evidenceio.ParseError) error {
+			lines = append(lines, err.Line)
+			return nil
+		}))
+		if err != nil {
+			t.Fatalf("%q: %v", input, err)
+		}
+		if diff := deep.Equal(got, want[1:]); diff != nil {
+			t.Errorf("%q: %v", input, diff)
+		}
+		if diff := deep.Equal(lines, []int{1}); diff != nil {
+			t.Errorf("%q: lines of invalid records: %v", input, diff)
+		}
+	}
+
+	// header with other column names is an invalid header, it is not skipped as invalid record
+	lines = nil
+	_, err = parse("source,target,pos,neg\n2,1,1,1\n", evidenceio.UseInvalidRecordHandler(func(err *evidenceio.ParseError) error {
+		lines = append(lines, err.Line)
+		return nil
+	}))
+	var parseErr *evidenceio.ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 1 || lines != nil {
+		t.Errorf("got error %v and invalid records of lines %v, want error of the header", err, lines)
+	}
+
+	errStop := errors.New("stop")
+	_, err = parse("1 2 3 1\n1 3 x 0\n", evidenceio.UseInvalidRecordHandler(func(*evidenceio.ParseError) error { return errStop }))
+	if err != errStop {
+		t.Errorf("got error %v, want %v", err, errStop)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	err := &evidenceio.ParseError{File: "evidence.csv", Line: 7, Err: errors.New("self-loop")}
+	if got, want := err.Error(), "evidence.csv:7: self-loop"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, format := range []evidenceio.Format{
+		evidenceio.AutoFormat,
+		evidenceio.WhitespaceFormat,
+		evidenceio.CSVFormat,
+		evidenceio.TSVFormat,
+		evidenceio.JSONLinesFormat,
+	} {
+		got, err := evidenceio.ParseFormat(format.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != format {
+			t.Errorf("got %v, want %v", got, format)
+		}
+	}
+	if _, err := evidenceio.ParseFormat("xml"); err == nil {
+		t.Error("expected error of unknown format")
+	}
+}
+
+// parsedEvidences are evidences parsed from the input
+type parsedEvidences string
+
+func (input parsedEvidences) GetEvidenceIterator() trust.EvidenceIterator {
+	return func(onNext trust.NextEvidenceHandler) error {
+		return evidenceio.Parse(strings.NewReader(string(input)), onNext, evidenceio.UseFileName("evidence.txt"))
+	}
+}
+
+func TestParseDuplicateEvidence(t *testing.T) {
+	_, err := make(trust.DirectReferralEvidence).FromIterableEvidences(
+		parsedEvidences("1 2 3 1\n2 1 1 1\n\n1 2 1 1\n"), trust.RejectDuplicateEvidence)
+
+	var parseErr *evidenceio.ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 4 {
+		t.Fatalf("got error %v, want parse error of line 4", err)
+	}
+	var duplicateErr *trust.DuplicateEvidenceError
+	if !errors.As(err, &duplicateErr) || duplicateErr.Link != (trust.Link{From: 1, To: 2}) {
+		t.Errorf("got error %v, want duplicate evidence error", err)
+	}
+}
@@ -0,0 +1,58 @@
+package trust
+
+import (
+	"github.com/dimchansky/ebsl-go/evidence"
+	"github.com/dimchansky/ebsl-go/opinion"
+)
+
+// DirectMultinomialFunctionalEvidence represents direct functional trust matrix in multinomial evidence space.
+// Link source is an entity and link destination is a proposition with several outcomes the entity has evidence about.
+type DirectMultinomialFunctionalEvidence map[Link]evidence.Multinomial
+
+// GetLinkIterator implements IterableLinks interface
+func (dfe DirectMultinomialFunctionalEvidence) GetLinkIterator() LinkIterator {
+	return func(onNext NextLinkHandler) error {
+		for link := range dfe {
+			if err := onNext(link); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// ToDirectMultinomialFunctionalOpinion transforms direct functional trust matrix to multinomial opinion space
+func (dfe DirectMultinomialFunctionalEvidence) ToDirectMultinomialFunctionalOpinion(c uint64) DirectMultinomialFunctionalOpinion {
+	res := make(DirectMultinomialFunctionalOpinion, len(dfe))
+	for link, ev := range dfe {
+		res[link] = opinion.MultinomialFromEvidence(c, ev)
+	}
+	return res
+}
+
+// DirectMultinomialFunctionalOpinion represents direct functional trust matrix in multinomial opinion space.
+// Link source is an entity and link destination is a proposition with several outcomes the entity has an opinion about.
+type DirectMultinomialFunctionalOpinion map[Link]opinion.Multinomial
+
+// GetLinkIterator implements IterableLinks interface
+func (dfo DirectMultinomialFunctionalOpinion) GetLinkIterator() LinkIterator {
+	return FinalMultinomialFunctionalOpinion(dfo).GetLinkIterator()
+}
+
+// FinalMultinomialFunctionalOpinion represents final functional trust matrix in multinomial opinion space:
+// link source is an entity and link destination is a proposition with several outcomes.
+type FinalMultinomialFunctionalOpinion map[Link]opinion.Multinomial
+
+// GetLinkIterator implements IterableLinks interface
+func (ffo FinalMultinomialFunctionalOpinion) GetLinkIterator() LinkIterator {
+	return func(onNext NextLinkHandler) error {
+		for link := range ffo {
+			if err := onNext(link); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
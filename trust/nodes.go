@@ -0,0 +1,138 @@
+package trust
+
+import (
+	"fmt"
+
+	"github.com/dimchansky/ebsl-go/opinion"
+)
+
+// NodeRegistry maps arbitrary string labels of nodes (emails, DIDs, public keys, etc.) to compact integer identifiers
+// used by links and back. Identifiers are assigned densely in order of registration starting from zero.
+// Registry is not safe for concurrent registration of labels.
+type NodeRegistry struct {
+	ids    map[string]uint64
+	labels []string
+}
+
+// NewNodeRegistry creates empty node registry
+func NewNodeRegistry() *NodeRegistry {
+	return &NodeRegistry{ids: make(map[string]uint64)}
+}
+
+// ID returns identifier of the node label, label is registered if it is not registered yet
+func (r *NodeRegistry) ID(label string) uint64 {
+	if id, ok := r.ids[label]; ok {
+		return id
+	}
+	id := uint64(len(r.labels))
+	r.ids[label] = id
+	r.labels = append(r.labels, label)
+	return id
+}
+
+// Lookup returns identifier of the registered node label
+func (r *NodeRegistry) Lookup(label string) (id uint64, ok bool) {
+	id, ok = r.ids[label]
+	return
+}
+
+// IDs returns identifiers of the registered node labels or error if some label is not registered
+func (r *NodeRegistry) IDs(labels ...string) ([]uint64, error) {
+	res := make([]uint64, 0, len(labels))
+	for _, label := range labels {
+		id, ok := r.ids[label]
+		if !ok {
+			return nil, fmt.Errorf("trust: unknown node: %v", label)
+		}
+		res = append(res, id)
+	}
+	return res, nil
+}
+
+// Label returns label of the node identifier
+func (r *NodeRegistry) Label(id uint64) (label string, ok bool) {
+	if id >= uint64(len(r.labels)) {
+		return "", false
+	}
+	return r.labels[id], true
+}
+
+// Len returns the number of registered nodes
+func (r *NodeRegistry) Len() int { return len(r.labels) }
+
+// Link returns link between labeled nodes registering labels if needed
+func (r *NodeRegistry) Link(from, to string) Link {
+	return Link{From: r.ID(from), To: r.ID(to)}
+}
+
+// LabeledLink returns link with labels of the nodes, identifiers not found in the registry are formatted as numbers
+func (r *NodeRegistry) LabeledLink(link Link) LabeledLink {
+	return LabeledLink{From: r.labelOrID(link.From), To: r.labelOrID(link.To)}
+}
+
+func (r *NodeRegistry) labelOrID(id uint64) string {
+	if label, ok := r.Label(id); ok {
+		return label
+	}
+	return fmt.Sprint(id)
+}
+
+// LabeledLink represents trust direction between labeled nodes
+type LabeledLink struct {
+	From string
+	To   string
+}
+
+// String implements fmt.Stringer interface
+func (l LabeledLink) String() string {
+	return fmt.Sprintf("%v -> %v", l.From, l.To)
+}
+
+// LabeledOpinions is a view of opinions about links between nodes with labels of the registry
+type LabeledOpinions struct {
+	registry *NodeRegistry
+	opinions map[Link]opinion.Type
+}
+
+// Labeled returns view of the final referral trust with labels of the registry
+func (fro FinalReferralOpinion) Labeled(r *NodeRegistry) LabeledOpinions {
+	return LabeledOpinions{registry: r, opinions: fro}
+}
+
+// Labeled returns view of the final functional trust with labels of the registry
+func (ffo FinalFunctionalOpinion) Labeled(r *NodeRegistry) LabeledOpinions {
+	return LabeledOpinions{registry: r, opinions: ffo}
+}
+
+// Get returns opinion of the link between labeled nodes, ok is false if some node is not registered
+// or there is no opinion of the link
+func (v LabeledOpinions) Get(from, to string) (o opinion.Type, ok bool) {
+	fromID, ok := v.registry.Lookup(from)
+	if !ok {
+		return o, false
+	}
+	toID, ok := v.registry.Lookup(to)
+	if !ok {
+		return o, false
+	}
+	o, ok = v.opinions[Link{From: fromID, To: toID}]
+	return
+}
+
+// NextLabeledOpinionHandler handles next opinion of the link between labeled nodes and returns error
+type NextLabeledOpinionHandler func(link LabeledLink, o opinion.Type) error
+
+// LabeledOpinionIterator used as `foreach` to handle all opinions of the links between labeled nodes
+type LabeledOpinionIterator func(NextLabeledOpinionHandler) error
+
+// GetLabeledOpinionIterator returns iterator over opinions sorted by links
+func (v LabeledOpinions) GetLabeledOpinionIterator() LabeledOpinionIterator {
+	return func(onNext NextLabeledOpinionHandler) error {
+		for _, link := range SortedLinks(DirectReferralOpinion(v.opinions)) {
+			if err := onNext(v.registry.LabeledLink(link), v.opinions[link]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
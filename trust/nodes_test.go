@@ -0,0 +1,88 @@
+package trust_test
+
+import (
+	"testing"
+
+	"github.com/dimchansky/ebsl-go/opinion"
+	"github.com/dimchansky/ebsl-go/trust"
+	"github.com/go-test/deep"
+)
+
+func TestNodeRegistry(t *testing.T) {
+	r := trust.NewNodeRegistry()
+
+	links := trust.Links{
+		r.Link("alice@example.com", "did:example:123"),
+		r.Link("did:example:123", "ed25519:9f2c"),
+		r.Link("ed25519:9f2c", "alice@example.com"),
+	}
+	want := trust.Links{{From: 0, To: 1}, {From: 1, To: 2}, {From: 2, To: 0}}
+	if diff := deep.Equal(links, want); diff != nil {
+		t.Errorf("links: %v", diff)
+	}
+	if r.Len() != 3 {
+		t.Errorf("got %v nodes, want 3", r.Len())
+	}
+
+	if got, want := r.LabeledLink(links[1]), (trust.LabeledLink{From: "did:example:123", To: "ed25519:9f2c"}); got != want {
+		t.Errorf("labeled link: got %v, want %v", got, want)
+	}
+	if got, want := r.LabeledLink(trust.Link{From: 2, To: 7}).String(), "ed25519:9f2c -> 7"; got != want {
+		t.Errorf("labeled link of unknown node: got %v, want %v", got, want)
+	}
+
+	ids, err := r.IDs("ed25519:9f2c", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(ids, []uint64{2, 0}); diff != nil {
+		t.Errorf("IDs: %v", diff)
+	}
+	if _, err := r.IDs("bob@example.com"); err == nil {
+		t.Error("expected error for unknown node")
+	}
+	if _, ok := r.Lookup("bob@example.com"); ok {
+		t.Error("lookup must not register node")
+	}
+}
+
+func TestLabeledOpinions(t *testing.T) {
+	r := trust.NewNodeRegistry()
+	aliceBob := opinion.New(0.5, 0.25, 0.25)
+	bobCarol := opinion.New(0.25, 0, 0.75)
+	fro := trust.FinalReferralOpinion{
+		r.Link("bob", "carol"): bobCarol,
+		r.Link("alice", "bob"): aliceBob,
+	}
+	r.ID("dave")
+
+	labeled := fro.Labeled(r)
+	if got, ok := labeled.Get("alice", "bob"); !ok || got != aliceBob {
+		t.Errorf("Get(alice, bob): got %v, %v, want %v", got, ok, aliceBob)
+	}
+	for _, link := range []trust.LabeledLink{{From: "alice", To: "carol"}, {From: "alice", To: "dave"}, {From: "eve", To: "bob"}} {
+		if got, ok := labeled.Get(link.From, link.To); ok {
+			t.Errorf("Get(%v, %v): got %v, want no opinion", link.From, link.To, got)
+		}
+	}
+
+	type labeledOpinion struct {
+		Link    trust.LabeledLink
+		Opinion opinion.Type
+	}
+	var got []labeledOpinion
+	foreachOpinion := trust.FinalFunctionalOpinion(fro).Labeled(r).GetLabeledOpinionIterator()
+	if err := foreachOpinion(func(link trust.LabeledLink, o opinion.Type) error {
+		got = append(got, labeledOpinion{link, o})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []labeledOpinion{
+		{trust.LabeledLink{From: "bob", To: "carol"}, bobCarol},
+		{trust.LabeledLink{From: "alice", To: "bob"}, aliceBob},
+	}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("labeled opinions: %v", diff)
+	}
+}
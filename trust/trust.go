@@ -2,6 +2,8 @@ package trust
 
 import (
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/dimchansky/ebsl-go/evidence"
 	"github.com/dimchansky/ebsl-go/opinion"
@@ -18,6 +20,42 @@ func (l Link) String() string {
 	return fmt.Sprintf("%v -> %v", l.From, l.To)
 }
 
+// Less reports whether link l must sort before link other: links are ordered by source and then by destination
+func (l Link) Less(other Link) bool {
+	if l.From != other.From {
+		return l.From < other.From
+	}
+	return l.To < other.To
+}
+
+// Links is a list of links
+type Links []Link
+
+// GetLinkIterator implements IterableLinks interface
+func (ls Links) GetLinkIterator() LinkIterator {
+	return func(onNext NextLinkHandler) error {
+		for _, link := range ls {
+			if err := onNext(link); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// SortedLinks collects all links and returns them sorted by source and then by destination
+func SortedLinks(links IterableLinks) Links {
+	var res Links
+	foreachLink := links.GetLinkIterator()
+	_ = foreachLink(func(link Link) error {
+		res = append(res, link)
+		return nil
+	})
+	sort.Slice(res, func(i, j int) bool { return res[i].Less(res[j]) })
+	return res
+}
+
 // NextLinkHandler handles next link and returns error
 type NextLinkHandler func(Link) error
 
@@ -40,6 +78,41 @@ type IterableEvidences interface {
 	GetEvidenceIterator() EvidenceIterator
 }
 
+// NextTimedEvidenceHandler handles next evidence with its timestamp (zero if evidence has no timestamp) and returns error
+type NextTimedEvidenceHandler func(Link, evidence.Type, time.Time) error
+
+// TimedEvidenceIterator used as `foreach` to handle all evidences with timestamps
+type TimedEvidenceIterator func(NextTimedEvidenceHandler) error
+
+// IterableTimedEvidences allows to iterate over all evidences with timestamps
+type IterableTimedEvidences interface {
+	GetTimedEvidenceIterator() TimedEvidenceIterator
+}
+
+// DecayedEvidences returns evidences decayed by their age at the reference time,
+// evidences without timestamp are not decayed
+func DecayedEvidences(evidences IterableTimedEvidences, decay evidence.DecayFun, reference time.Time) IterableEvidences {
+	return decayedEvidences{evidences: evidences, decay: decay, reference: reference}
+}
+
+type decayedEvidences struct {
+	evidences IterableTimedEvidences
+	decay     evidence.DecayFun
+	reference time.Time
+}
+
+func (d decayedEvidences) GetEvidenceIterator() EvidenceIterator {
+	return func(onNext NextEvidenceHandler) error {
+		foreachEvidence := d.evidences.GetTimedEvidenceIterator()
+		return foreachEvidence(func(link Link, ev evidence.Type, timestamp time.Time) error {
+			if !timestamp.IsZero() {
+				ev = evidence.Decayed(ev, d.decay, d.reference.Sub(timestamp))
+			}
+			return onNext(link, ev)
+		})
+	}
+}
+
 // DirectReferralEvidence represents direct referral trust matrix in evidence space
 type DirectReferralEvidence map[Link]evidence.Type
 
@@ -69,6 +142,16 @@ func (dre DirectReferralEvidence) GetEvidenceIterator() EvidenceIterator {
 	}
 }
 
+// FromIterableEvidences adds evidences to DirectReferralEvidence, evidences of the same link are aggregated
+// (evidence already stored in DirectReferralEvidence is replaced)
+func (dre DirectReferralEvidence) FromIterableEvidences(evidences IterableEvidences, aggregate AggregationFun) (DirectReferralEvidence, error) {
+	err := AggregateEvidences(evidences, aggregate, func(link Link, ev evidence.Type) error {
+		dre[link] = ev
+		return nil
+	})
+	return dre, err
+}
+
 // ToDirectReferralOpinion transforms direct referral trust matrix to opinion space
 func (dre DirectReferralEvidence) ToDirectReferralOpinion(c uint64) DirectReferralOpinion {
 	return make(DirectReferralOpinion, len(dre)).
@@ -78,7 +161,8 @@ func (dre DirectReferralEvidence) ToDirectReferralOpinion(c uint64) DirectReferr
 // DirectReferralOpinion represents direct referral trust matrix in opinion space
 type DirectReferralOpinion map[Link]opinion.Type
 
-// FromIterableEvidences builds DirectReferralOpinion from IterableEvidences
+// FromIterableEvidences builds DirectReferralOpinion from IterableEvidences,
+// only the last evidence of the link is kept (use FromAggregatedEvidences to aggregate evidences of the link)
 func (dro DirectReferralOpinion) FromIterableEvidences(evidences IterableEvidences, c uint64) DirectReferralOpinion {
 	foreachEvidence := evidences.GetEvidenceIterator()
 	_ = foreachEvidence(func(link Link, ev evidence.Type) error {
@@ -88,6 +172,16 @@ func (dro DirectReferralOpinion) FromIterableEvidences(evidences IterableEvidenc
 	return dro
 }
 
+// FromAggregatedEvidences builds DirectReferralOpinion from IterableEvidences,
+// evidences of the same link are aggregated before they are converted to opinion
+func (dro DirectReferralOpinion) FromAggregatedEvidences(evidences IterableEvidences, c uint64, aggregate AggregationFun) (DirectReferralOpinion, error) {
+	err := AggregateEvidences(evidences, aggregate, func(link Link, ev evidence.Type) error {
+		dro[link] = opinion.FromEvidence(c, ev)
+		return nil
+	})
+	return dro, err
+}
+
 // GetLinkIterator implements IterableLinks interface
 func (dro DirectReferralOpinion) GetLinkIterator() LinkIterator {
 	return func(onNext NextLinkHandler) error {
@@ -101,8 +195,101 @@ func (dro DirectReferralOpinion) GetLinkIterator() LinkIterator {
 	}
 }
 
+// DirectReferralOpinionDelta represents change of the direct referral trust matrix
+type DirectReferralOpinionDelta struct {
+	// Updated contains added links and links with changed opinion
+	Updated DirectReferralOpinion
+	// Removed contains removed links
+	Removed Links
+}
+
+// GetLinkIterator implements IterableLinks interface, it iterates over updated and removed links
+func (d *DirectReferralOpinionDelta) GetLinkIterator() LinkIterator {
+	return func(onNext NextLinkHandler) error {
+		if err := d.Updated.GetLinkIterator()(onNext); err != nil {
+			return err
+		}
+		return d.Removed.GetLinkIterator()(onNext)
+	}
+}
+
+// ApplyTo updates direct referral trust matrix with the change in place and returns the same (updated) matrix
+func (d *DirectReferralOpinionDelta) ApplyTo(dro DirectReferralOpinion) DirectReferralOpinion {
+	for _, link := range d.Removed {
+		delete(dro, link)
+	}
+	for link, value := range d.Updated {
+		dro[link] = value
+	}
+	return dro
+}
+
 // DirectFunctionalTrust is the direct opinion about an entity's ability to provide a specific function
 type DirectFunctionalTrust map[uint64]opinion.Type
 
+// DirectFunctionalEvidence represents direct functional trust matrix in evidence space.
+// Link source is an entity and link destination is a proposition the entity has evidence about.
+type DirectFunctionalEvidence map[Link]evidence.Type
+
+// GetLinkIterator implements IterableLinks interface
+func (dfe DirectFunctionalEvidence) GetLinkIterator() LinkIterator {
+	return DirectReferralEvidence(dfe).GetLinkIterator()
+}
+
+// GetEvidenceIterator implements IterableEvidences interface
+func (dfe DirectFunctionalEvidence) GetEvidenceIterator() EvidenceIterator {
+	return DirectReferralEvidence(dfe).GetEvidenceIterator()
+}
+
+// FromIterableEvidences adds evidences to DirectFunctionalEvidence, evidences of the same link are aggregated
+// (evidence already stored in DirectFunctionalEvidence is replaced)
+func (dfe DirectFunctionalEvidence) FromIterableEvidences(evidences IterableEvidences, aggregate AggregationFun) (DirectFunctionalEvidence, error) {
+	_, err := DirectReferralEvidence(dfe).FromIterableEvidences(evidences, aggregate)
+	return dfe, err
+}
+
+// ToDirectFunctionalOpinion transforms direct functional trust matrix to opinion space
+func (dfe DirectFunctionalEvidence) ToDirectFunctionalOpinion(c uint64) DirectFunctionalOpinion {
+	return make(DirectFunctionalOpinion, len(dfe)).
+		FromIterableEvidences(dfe, c)
+}
+
+// DirectFunctionalOpinion represents direct functional trust matrix in opinion space.
+// Link source is an entity and link destination is a proposition the entity has an opinion about.
+type DirectFunctionalOpinion map[Link]opinion.Type
+
+// FromIterableEvidences builds DirectFunctionalOpinion from IterableEvidences,
+// only the last evidence of the link is kept (use FromAggregatedEvidences to aggregate evidences of the link)
+func (dfo DirectFunctionalOpinion) FromIterableEvidences(evidences IterableEvidences, c uint64) DirectFunctionalOpinion {
+	DirectReferralOpinion(dfo).FromIterableEvidences(evidences, c)
+	return dfo
+}
+
+// FromAggregatedEvidences builds DirectFunctionalOpinion from IterableEvidences,
+// evidences of the same link are aggregated before they are converted to opinion
+func (dfo DirectFunctionalOpinion) FromAggregatedEvidences(evidences IterableEvidences, c uint64, aggregate AggregationFun) (DirectFunctionalOpinion, error) {
+	_, err := DirectReferralOpinion(dfo).FromAggregatedEvidences(evidences, c, aggregate)
+	return dfo, err
+}
+
+// GetLinkIterator implements IterableLinks interface
+func (dfo DirectFunctionalOpinion) GetLinkIterator() LinkIterator {
+	return DirectReferralOpinion(dfo).GetLinkIterator()
+}
+
 // FinalReferralOpinion represents final referral trust matrix in opinion space
 type FinalReferralOpinion map[Link]opinion.Type
+
+// GetLinkIterator implements IterableLinks interface
+func (fro FinalReferralOpinion) GetLinkIterator() LinkIterator {
+	return DirectReferralOpinion(fro).GetLinkIterator()
+}
+
+// FinalFunctionalOpinion represents final functional trust matrix in opinion space:
+// link source is an entity and link destination is a proposition.
+type FinalFunctionalOpinion map[Link]opinion.Type
+
+// GetLinkIterator implements IterableLinks interface
+func (ffo FinalFunctionalOpinion) GetLinkIterator() LinkIterator {
+	return DirectReferralOpinion(ffo).GetLinkIterator()
+}
@@ -46,3 +46,55 @@ func BenchmarkCreateEquationsAndSolveThemInOneEpoch(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkSolveEquationsWithAcceleration(b *testing.B) {
+	const nodes = 50
+
+	// every node trusts next 10 nodes in the ring with nearly-full-uncertainty opinions,
+	// Jacobi update needs about a hundred epochs to solve such equations with high precision
+	dro := make(trust.DirectReferralOpinion)
+	for i := uint64(0); i < nodes; i++ {
+		for k := uint64(1); k <= 10; k++ {
+			dro[trust.Link{From: i, To: (i + k) % nodes}] = opinion.FromEvidence(2, evidence.New(0.2, 0))
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts []solver.Options
+	}{
+		{"plain", nil},
+		{"anderson", []solver.Options{solver.UseAndersonAcceleration(5)}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			eqs := equations.CreateFinalReferralTrustEquations(dro, equations.UseSortedOrder())
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			var epochs uint
+			for i := 0; i < b.N; i++ {
+				context := equations.NewDefaultFinalReferralTrustEquationContext(dro)
+
+				res, err := solver.SolveFinalReferralTrustEquationsWithResult(
+					context,
+					eqs,
+					append([]solver.Options{
+						solver.UseMaxEpochs(1000),
+						solver.UseTolerance(1e-12),
+						solver.UseUpdateMode(solver.JacobiUpdate),
+					}, tc.opts...)...,
+				)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if !res.Converged {
+					b.Fatal("equations did not converge")
+				}
+				epochs = res.Epochs
+			}
+
+			b.ReportMetric(float64(epochs), "epochs")
+		})
+	}
+}